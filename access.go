@@ -0,0 +1,113 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AccessFull     = "full"     //body, abstract and metadata
+	AccessAbstract = "abstract" //abstract and metadata
+	AccessMetadata = "metadata" //bibliographic metadata only
+)
+
+type Access struct {
+	Level  string `bson:"level,omitempty" json:"level,omitempty"`
+	Reason string `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// licenses, by spdx identifier without the version, under which we may serve the full text;
+// the NC and ND variants don't allow redistribution by us
+var OpenLicenses = map[string]bool{"CC-BY": true, "CC-BY-SA": true, "CC0": true, "PDM": true}
+
+// decides how much of an article may be served, replaceable for other redistribution rules
+var ArticleAccessPolicy = DefaultAccessPolicy
+
+func DefaultAccessPolicy(article *Article) (access Access) {
+	release := article.PmcRelease.Time
+	if !release.IsZero() && release.After(time.Now()) {
+		return Access{
+			Level:  AccessMetadata,
+			Reason: "embargoed until " + release.Format("2006-01-02"),
+		}
+	}
+
+	if article.Withheld {
+		return Access{
+			Level:  AccessAbstract,
+			Reason: "full text withheld by the publisher",
+		}
+	}
+
+	//a recognised license decides, PMC also marks NC and ND licenses as open-access
+	spdx := ""
+	for _, license := range article.Permissions.LicenseDetails {
+		if OpenLicenses[LicenseFamily(license.Spdx)] {
+			return Access{
+				Level:  AccessFull,
+				Reason: "open license " + license.Spdx,
+			}
+		}
+		if spdx == "" {
+			spdx = license.Spdx
+		}
+	}
+	if spdx != "" {
+		return Access{
+			Level:  AccessAbstract,
+			Reason: "license " + spdx + " is not open",
+		}
+	}
+	for _, licenseType := range article.Permissions.Licenses {
+		if licenseType == "open-access" {
+			return Access{
+				Level:  AccessFull,
+				Reason: "open-access license-type",
+			}
+		}
+	}
+
+	if len(article.Permissions.LicenseDetails) == 0 && len(article.Permissions.Licenses) == 0 {
+		return Access{
+			Level:  AccessAbstract,
+			Reason: "no license",
+		}
+	}
+	return Access{
+		Level:  AccessAbstract,
+		Reason: "license " + article.Permissions.Spdx + " is not open",
+	}
+}
+
+func (this *Access) Allows(level string) bool {
+	rank := map[string]int{
+		AccessMetadata: 1,
+		AccessAbstract: 2,
+		AccessFull:     3,
+	}
+	return rank[this.Level] >= rank[level]
+}
+
+func (this *Article) Access() Access {
+	return ArticleAccessPolicy(this)
+}
+
+func (this *Article) RenderBody() (output string, access Access) {
+	return DefaultRenderer.RenderBody(this, nil)
+}
+
+func (this *Article) Export() (article Article, access Access) {
+	//a copy with everything the access level doesn't allow removed
+	access = this.Access()
+	article = *this
+	if !access.Allows(AccessFull) {
+		article.Body = nil
+		article.Ack = nil
+		article.Back = nil
+		article.SubArticles = nil
+	}
+	if !access.Allows(AccessAbstract) {
+		article.Abstract = nil
+		article.Abstracts = nil
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessPolicy(t *testing.T) {
+	tests := []struct {
+		spdx  string
+		level string
+	}{
+		{"CC-BY-4.0", AccessFull},
+		{"CC-BY", AccessFull},
+		{"CC-BY-SA-3.0", AccessFull},
+		{"CC0-1.0", AccessFull},
+		{"PDM", AccessFull},
+		{"CC-BY-NC-4.0", AccessAbstract},
+		{"CC-BY-ND-4.0", AccessAbstract},
+		{"CC-BY-NC-ND-4.0", AccessAbstract},
+		{"CC-BY-NC-SA-3.0", AccessAbstract},
+	}
+	for _, test := range tests {
+		article := Article{Permissions: Permissions{LicenseDetails: []License{{Spdx: test.spdx}}}}
+		if access := article.Access(); access.Level != test.level {
+			t.Errorf("%s: level %q (%s), want %q", test.spdx, access.Level, access.Reason, test.level)
+		}
+	}
+
+	//the license-type only counts when no license was recognised
+	nonCommercial := Article{Permissions: Permissions{
+		Licenses:       []string{"open-access"},
+		LicenseDetails: []License{{Type: "open-access", Spdx: "CC-BY-NC-ND-4.0"}},
+	}}
+	if access := nonCommercial.Access(); access.Level != AccessAbstract {
+		t.Errorf("open-access CC-BY-NC-ND-4.0: level %q (%s), want %q", access.Level, access.Reason, AccessAbstract)
+	}
+	parsed := parseArticle(t, `<article><front><article-meta><permissions><license license-type="open-access" xlink:href="https://creativecommons.org/licenses/by-nc-nd/4.0/"><license-p>Some rights reserved.</license-p></license></permissions></article-meta></front></article>`)
+	if access := parsed.Access(); access.Level != AccessAbstract {
+		t.Errorf("parsed open-access by-nc-nd: level %q (%s), want %q", access.Level, access.Reason, AccessAbstract)
+	}
+	unrecognised := Article{Permissions: Permissions{
+		Licenses:       []string{"open-access"},
+		LicenseDetails: []License{{Type: "open-access", Url: "https://example.org/license"}},
+	}}
+	if access := unrecognised.Access(); access.Level != AccessFull {
+		t.Errorf("open-access without spdx: level %q (%s), want %q", access.Level, access.Reason, AccessFull)
+	}
+
+	embargoed := Article{Permissions: Permissions{Licenses: []string{"open-access"}}}
+	embargoed.PmcRelease.Time = time.Now().Add(24 * time.Hour)
+	if access := embargoed.Access(); access.Level != AccessMetadata {
+		t.Errorf("embargoed: level %q, want %q", access.Level, AccessMetadata)
+	}
+}
+
+func TestRenderingChecksAccess(t *testing.T) {
+	article := parseArticle(t, `<article><front><article-meta><abstract><p>Summary.</p></abstract></article-meta></front><body><sec><title>Methods</title><p>Secret.</p></sec></body></article>`)
+
+	if output, access := article.RenderBody(); output != "" || access.Level != AccessAbstract {
+		t.Errorf("RenderBody without a license = %q, %q", output, access.Level)
+	}
+	if output, _ := article.RenderTableOfContents(); output != "" {
+		t.Errorf("RenderTableOfContents without a license = %q", output)
+	}
+	if output, _ := DefaultRenderer.RenderBody(&article, nil); output != "" {
+		t.Errorf("Renderer.RenderBody without a license = %q", output)
+	}
+	if output, _ := article.RenderAbstract("", ""); output == "" {
+		t.Errorf("RenderAbstract without a license is empty")
+	}
+
+	article.Permissions.Licenses = []string{"open-access"}
+	if output, _ := article.RenderBody(); output == "" {
+		t.Errorf("RenderBody with an open license is empty")
+	}
+}
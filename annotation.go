@@ -0,0 +1,292 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"gopkg.in/mgo.v2/bson"
+
+	"nofe/db"
+)
+
+var (
+	ErrNoAnnotation    = errors.New("no such annotation")
+	ErrNoSentence      = errors.New("no sentence with that id")
+	ErrAnnotationRange = errors.New("annotation range outside the sentence")
+)
+
+// how alike the old and new sentence must be, as a share of common words, to move an annotation onto it
+var ReanchorThreshold = 0.5
+
+type Annotation struct {
+	Id         bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty"`
+	UserId     string        `bson:"userId,omitempty" json:"userId,omitempty"`
+	ArticleId  bson.ObjectId `bson:"articleId,omitempty" json:"articleId,omitempty"`
+	SentenceId string        `bson:"sentenceId,omitempty" json:"sentenceId,omitempty"`
+	Start      int           `bson:"start" json:"start"` //UTF-16 code units into the sentence text
+	End        int           `bson:"end" json:"end"`
+	Note       string        `bson:"note,omitempty" json:"note,omitempty"`
+	Created    time.Time     `bson:"created,omitempty" json:"created,omitempty"`
+	Updated    time.Time     `bson:"updated,omitempty" json:"updated,omitempty"`
+
+	//what was annotated, for finding it again after the article is re-imported
+	Quote    string `bson:"quote,omitempty" json:"quote,omitempty"`
+	Context  string `bson:"context,omitempty" json:"context,omitempty"`   //the whole sentence
+	Orphaned bool   `bson:"orphaned,omitempty" json:"orphaned,omitempty"` //no longer found in the article
+}
+
+func (this *Article) Anchor(annotation *Annotation) (err error) {
+	//check the range against the sentence and remember the text it covers
+	location, found := this.SentenceById(annotation.SentenceId)
+	if !found {
+		return ErrNoSentence
+	}
+	if annotation.Start < 0 || annotation.End > Utf16Len(location.Text) || annotation.Start >= annotation.End {
+		return ErrAnnotationRange
+	}
+	annotation.ArticleId = this.Id
+	annotation.Quote = SentenceText(location.Text, Sentence{Start: annotation.Start, End: annotation.End})
+	annotation.Context = location.Text
+	annotation.Orphaned = false
+	return
+}
+
+func (this *Article) Reanchor(annotation *Annotation) (changed bool) {
+	//move annotation to where its quote is now, it is orphaned if there is no similar sentence containing it
+	location, found := this.SentenceById(annotation.SentenceId)
+	if found && annotation.End <= Utf16Len(location.Text) &&
+		SentenceText(location.Text, Sentence{Start: annotation.Start, End: annotation.End}) == annotation.Quote {
+		changed = annotation.Orphaned
+		annotation.Orphaned = false
+		return
+	}
+
+	best, bestScore := Annotation{}, -1.0
+	for _, scope := range this.sentenceScopes() {
+		walkSentences(scope.nodes, "", "", func(block *Node, path string, sectionId string) {
+			body := inlineText(block.Children)
+			for _, sent := range block.Sentences {
+				text := SentenceText(body, sent)
+				score := wordSimilarity(annotation.Context, text)
+				if score < ReanchorThreshold || score <= bestScore {
+					continue
+				}
+				start, end := quoteRange(text, annotation.Quote, annotation.Start)
+				if end == 0 {
+					continue
+				}
+				best, bestScore = *annotation, score
+				best.SentenceId, best.Start, best.End = sent.Id, start, end
+				best.Context = text
+			}
+		})
+	}
+
+	if bestScore < 0 {
+		changed = !annotation.Orphaned
+		annotation.Orphaned = true
+		return
+	}
+	best.Orphaned = false
+	*annotation = best
+	return true
+}
+
+func quoteRange(text string, quote string, near int) (start int, end int) {
+	//UTF-16 range of the occurrence of quote in text closest to near, 0, 0 if there is none
+	if quote == "" {
+		return
+	}
+	distance := -1
+	for from := 0; from <= len(text); {
+		i := strings.Index(text[from:], quote)
+		if i < 0 {
+			break
+		}
+		s := Utf16Offset(text, from+i)
+		d := s - near
+		if d < 0 {
+			d = -d
+		}
+		if distance < 0 || d < distance {
+			distance, start = d, s
+			end = s + Utf16Len(quote)
+		}
+		from += i + 1
+	}
+	return
+}
+
+func wordSimilarity(a string, b string) float64 {
+	//dice coefficient over the lowercased words of a and b
+	wordsA, wordsB := wordSet(a), wordSet(b)
+	if len(wordsA)+len(wordsB) == 0 {
+		return 1
+	}
+	common := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(wordsA)+len(wordsB))
+}
+
+func wordSet(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		words[strings.Trim(word, `.,;:!?"'()[]{}`)] = true
+	}
+	return words
+}
+
+func (this *Article) RenderBodyAnnotated(annotations []Annotation) (output string, access Access) {
+	//as RenderBody, with the annotated ranges wrapped in marks
+	return DefaultRenderer.RenderBody(this, annotations)
+}
+
+func (this *Renderer) renderMarks(body string, start int, end int, offset int, sent Sentence, annotations []Annotation) (output string) {
+	//body[start:end] is a fragment of sent, split where annotations begin or end and marked where covered
+	if len(annotations) == 0 {
+		return html.EscapeString(body[start:end])
+	}
+	bounds := func(annotation Annotation) (int, int) {
+		return ByteOffset(body, sent.Start+annotation.Start-offset), ByteOffset(body, sent.Start+annotation.End-offset)
+	}
+
+	cuts := []int{start, end}
+	for _, annotation := range annotations {
+		from, to := bounds(annotation)
+		for _, cut := range []int{from, to} {
+			if cut > start && cut < end {
+				cuts = append(cuts, cut)
+			}
+		}
+	}
+	sort.Ints(cuts)
+
+	for i := 0; i+1 < len(cuts); i++ {
+		from, to := cuts[i], cuts[i+1]
+		if from == to {
+			continue
+		}
+		ids := []string{}
+		for _, annotation := range annotations {
+			a, b := bounds(annotation)
+			if a <= from && b >= to {
+				ids = append(ids, annotation.Id.Hex())
+			}
+		}
+		if len(ids) == 0 {
+			output += html.EscapeString(body[from:to])
+			continue
+		}
+		output += "<mark class=\"" + html.EscapeString(this.MarkClass) + "\" data-annotation-ids=\"" + strings.Join(ids, " ") + "\">" + html.EscapeString(body[from:to]) + "</mark>"
+	}
+	return
+}
+
+func AnnotationCreate(article *Article, annotation Annotation) (created Annotation, err error) {
+	err = article.Anchor(&annotation)
+	if err != nil {
+		return
+	}
+	annotation.Id = bson.NewObjectId()
+	annotation.Created = time.Now()
+	annotation.Updated = annotation.Created
+
+	err = db.GetCol("annotations").Insert(annotation)
+	if err != nil {
+		return
+	}
+	return annotation, nil
+}
+
+func AnnotationGetById(id string) (annotation Annotation, err error) {
+	if !bson.IsObjectIdHex(id) {
+		err = ErrNoAnnotation
+		return
+	}
+
+	err = db.GetCol("annotations").FindId(bson.ObjectIdHex(id)).One(&annotation)
+	return
+}
+
+func AnnotationListByArticle(articleId string, userId string) (annotations []Annotation, err error) {
+	//every user's annotations when userId is empty
+	if !bson.IsObjectIdHex(articleId) {
+		return
+	}
+
+	query := bson.M{
+		"articleId": bson.ObjectIdHex(articleId),
+	}
+	if userId != "" {
+		query["userId"] = userId
+	}
+
+	err = db.GetCol("annotations").Find(query).Sort("created").All(&annotations)
+	return
+}
+
+func AnnotationUpdate(article *Article, annotation Annotation) (updated Annotation, err error) {
+	//a changed range is checked against article again
+	err = article.Anchor(&annotation)
+	if err != nil {
+		return
+	}
+	annotation.Updated = time.Now()
+
+	err = db.GetCol("annotations").UpdateId(annotation.Id, bson.M{"$set": bson.M{
+		"sentenceId": annotation.SentenceId,
+		"start":      annotation.Start,
+		"end":        annotation.End,
+		"note":       annotation.Note,
+		"updated":    annotation.Updated,
+		"quote":      annotation.Quote,
+		"context":    annotation.Context,
+		"orphaned":   annotation.Orphaned,
+	}})
+	if err != nil {
+		return
+	}
+	return annotation, nil
+}
+
+func AnnotationDelete(id string) (err error) {
+	if !bson.IsObjectIdHex(id) {
+		return ErrNoAnnotation
+	}
+
+	err = db.GetCol("annotations").RemoveId(bson.ObjectIdHex(id))
+	return
+}
+
+func AnnotationReanchorArticle(article *Article) (err error) {
+	//after a re-import, follow the annotated text to its new sentences
+	annotations, err := AnnotationListByArticle(article.Id.Hex(), "")
+	if err != nil {
+		return
+	}
+
+	article.AssignSentenceIds()
+	for _, annotation := range annotations {
+		if !article.Reanchor(&annotation) {
+			continue
+		}
+		err = db.GetCol("annotations").UpdateId(annotation.Id, bson.M{"$set": bson.M{
+			"sentenceId": annotation.SentenceId,
+			"start":      annotation.Start,
+			"end":        annotation.End,
+			"context":    annotation.Context,
+			"orphaned":   annotation.Orphaned,
+		}})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func sentenceId(t *testing.T, article *Article, text string) string {
+	//id of the sentence whose text contains text
+	for _, scope := range article.sentenceScopes() {
+		id := ""
+		walkSentences(scope.nodes, "", "", func(block *Node, path string, sectionId string) {
+			body := inlineText(block.Children)
+			for _, sent := range block.Sentences {
+				if id == "" && strings.Contains(SentenceText(body, sent), text) {
+					id = sent.Id
+				}
+			}
+		})
+		if id != "" {
+			return id
+		}
+	}
+	t.Fatalf("no sentence containing %q", text)
+	return ""
+}
+
+func annotate(t *testing.T, article *Article, sentence string, quote string) Annotation {
+	id := sentenceId(t, article, sentence)
+	location, _ := article.SentenceById(id)
+	start := Utf16Offset(location.Text, strings.Index(location.Text, quote))
+	annotation := Annotation{Id: bson.NewObjectId(), SentenceId: id, Start: start, End: start + Utf16Len(quote)}
+	if err := article.Anchor(&annotation); err != nil {
+		t.Fatal(err)
+	}
+	return annotation
+}
+
+func TestReanchor(t *testing.T) {
+	original := parseArticle(t, `<article><body><sec id="s1"><p>Cells were grown overnight in rich medium. The samples were then frozen at low temperature.</p></sec></body></article>`)
+	annotation := annotate(t, &original, "frozen", "frozen at low temperature")
+
+	//unchanged
+	unchanged := annotation
+	if original.Reanchor(&unchanged) || unchanged != annotation {
+		t.Errorf("unchanged article moved the annotation: %+v", unchanged)
+	}
+
+	//the sentence moved into another section and gained a word
+	edited := parseArticle(t, `<article><body><sec id="s1"><p>Cells were grown overnight in rich medium.</p></sec>`+
+		`<sec id="s2"><p>A new first sentence. The samples were then quickly frozen at low temperature.</p></sec></body></article>`)
+	moved := annotation
+	if !edited.Reanchor(&moved) {
+		t.Fatal("edited sentence did not re-anchor")
+	}
+	location, found := edited.SentenceById(moved.SentenceId)
+	if !found || moved.Orphaned {
+		t.Fatalf("re-anchored to a missing sentence: %+v", moved)
+	}
+	if quote := SentenceText(location.Text, Sentence{Start: moved.Start, End: moved.End}); quote != annotation.Quote {
+		t.Errorf("re-anchored quote %q, want %q", quote, annotation.Quote)
+	}
+	if location.SectionId != "s2" {
+		t.Errorf("re-anchored in section %q, want s2", location.SectionId)
+	}
+
+	//the sentence is gone
+	removed := parseArticle(t, `<article><body><sec id="s1"><p>Cells were grown overnight in rich medium.</p></sec></body></article>`)
+	orphan := annotation
+	if !removed.Reanchor(&orphan) || !orphan.Orphaned {
+		t.Errorf("annotation on a removed sentence not orphaned: %+v", orphan)
+	}
+	if removed.Reanchor(&orphan) {
+		t.Error("orphaning an orphaned annotation reported a change")
+	}
+}
+
+func TestQuoteRange(t *testing.T) {
+	tests := []struct {
+		text       string
+		quote      string
+		near       int
+		start, end int
+	}{
+		{"a b a b", "a b", 0, 0, 3},
+		{"a b a b", "a b", 4, 4, 7},
+		{"α β α", "α", 3, 4, 5},
+		{"abc", "x", 0, 0, 0},
+		{"abc", "", 0, 0, 0},
+	}
+	for _, test := range tests {
+		start, end := quoteRange(test.text, test.quote, test.near)
+		if start != test.start || end != test.end {
+			t.Errorf("quoteRange(%q, %q, %d) = %d, %d, want %d, %d", test.text, test.quote, test.near, start, end, test.start, test.end)
+		}
+	}
+}
+
+func TestWordSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"The cells grew.", "the cells grew", 1},
+		{"one two", "three four", 0},
+		{"one two three four", "one two five six", 0.5},
+		{"", "", 1},
+	}
+	for _, test := range tests {
+		if got := wordSimilarity(test.a, test.b); got != test.want {
+			t.Errorf("wordSimilarity(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestRenderOverlappingMarks(t *testing.T) {
+	article := parseArticle(t, `<article><body><sec id="s1"><p>The <italic>p53</italic> protein binds DNA.</p></sec></body></article>`)
+	first := annotate(t, &article, "protein", "The p53")
+	second := annotate(t, &article, "protein", "p53 protein")
+
+	output := DefaultRenderer.renderNodesAnnotated(article.Body, 0, nil, "1", []Annotation{first, second})
+	both := first.Id.Hex() + " " + second.Id.Hex()
+	for _, want := range []string{
+		`data-annotation-ids="` + first.Id.Hex() + `">The </mark>`,
+		`<mark class="` + DefaultRenderer.MarkClass + `" data-annotation-ids="` + both + `">p53</mark></span></i>`,
+		`data-annotation-ids="` + second.Id.Hex() + `"> protein</mark>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("no %s in %s", want, output)
+		}
+	}
+	if strings.Count(output, "<mark") != 3 {
+		t.Errorf("%d marks, want 3: %s", strings.Count(output, "<mark"), output)
+	}
+
+	orphaned := first
+	orphaned.Orphaned = true
+	if output := DefaultRenderer.renderNodesAnnotated(article.Body, 0, nil, "1", []Annotation{orphaned}); strings.Contains(output, "<mark") {
+		t.Errorf("orphaned annotation rendered: %s", output)
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// words per minute used for ReadingMinutes
+const ReadingSpeed = 200
+
+type Counts struct {
+	Figures        int `bson:"figures,omitempty" json:"figures,omitempty"`
+	Tables         int `bson:"tables,omitempty" json:"tables,omitempty"`
+	Equations      int `bson:"equations,omitempty" json:"equations,omitempty"`
+	Refs           int `bson:"refs,omitempty" json:"refs,omitempty"`
+	Pages          int `bson:"pages,omitempty" json:"pages,omitempty"`
+	Words          int `bson:"words,omitempty" json:"words,omitempty"`
+	ReadingMinutes int `bson:"readingMinutes,omitempty" json:"readingMinutes,omitempty"`
+}
+
+func (this *Article) ParseCounts(n *html.Node) (err error) {
+
+	var count *int
+	switch n.Data {
+	case "fig-count":
+		count = &this.Counts.Figures
+	case "table-count":
+		count = &this.Counts.Tables
+	case "equation-count":
+		count = &this.Counts.Equations
+	case "ref-count":
+		count = &this.Counts.Refs
+	case "page-count":
+		count = &this.PageCount
+	case "word-count":
+		count = &this.Counts.Words
+	}
+	if count != nil {
+		for _, a := range n.Attr {
+			if a.Key == "count" {
+				*count, err = strconv.Atoi(strings.TrimSpace(a.Val))
+				err = this.check(n, err)
+				if err != nil {
+					return
+				}
+				break
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		err = this.ParseCounts(c)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (this *Article) ComputeCounts() {
+	//fill in whatever <counts> didn't declare from the parsed article
+	this.Counts.Pages = this.PageCount
+	if this.Counts.Pages == 0 && this.Fpage != "" && this.Lpage != "" {
+		first, err1 := strconv.Atoi(this.Fpage)
+		last, err2 := strconv.Atoi(this.Lpage)
+		if err1 == nil && err2 == nil && last >= first {
+			this.Counts.Pages = last - first + 1
+		}
+	}
+
+	tags := map[string]int{}
+	countTags(this.Body, tags)
+	if this.Counts.Figures == 0 {
+		this.Counts.Figures = tags["fig"]
+	}
+	if this.Counts.Tables == 0 {
+		this.Counts.Tables = tags["table-wrap"]
+	}
+	if this.Counts.Equations == 0 {
+		this.Counts.Equations = tags["disp-formula"]
+	}
+	if this.Counts.Refs == 0 {
+		this.Counts.Refs = len(this.Refs.List)
+	}
+	if this.Counts.Words == 0 {
+		this.Counts.Words = this.BodyStats().Words
+	}
+	this.Counts.ReadingMinutes = (this.Counts.Words + ReadingSpeed - 1) / ReadingSpeed
+}
+
+func countTags(nodes []Node, tags map[string]int) {
+	for _, node := range nodes {
+		if node.Type != "tag" {
+			continue
+		}
+		tags[node.Tag]++
+		countTags(node.Children, tags)
+	}
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	DatePrecisionNone   = ""
+	DatePrecisionYear   = "year"
+	DatePrecisionSeason = "season"
+	DatePrecisionMonth  = "month"
+	DatePrecisionDay    = "day"
+)
+
+var months = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+// layouts tried on string-date, for dates given only as text, e.g. "March 3, 2020"
+var stringDateLayouts = []struct{ layout, precision string }{
+	{"January 2 2006", DatePrecisionDay},
+	{"Jan 2 2006", DatePrecisionDay},
+	{"2 January 2006", DatePrecisionDay},
+	{"2 Jan 2006", DatePrecisionDay},
+	{"2006-01-02", DatePrecisionDay},
+	{"January 2006", DatePrecisionMonth},
+	{"Jan 2006", DatePrecisionMonth},
+	{"2006-01", DatePrecisionMonth},
+	{"2006", DatePrecisionYear},
+}
+
+var seasons = map[string]time.Month{
+	"spring": time.March,
+	"summer": time.June,
+	"fall":   time.September,
+	"autumn": time.September,
+	"winter": time.December,
+}
+
+func ParseDate(n *html.Node) (date Date) {
+	date.Day = ParseChildText(n, "day")
+	date.Month = ParseChildText(n, "month")
+	date.Year = ParseChildText(n, "year")
+	date.Season = ParseChildText(n, "season")
+	date.StringDate = ParseChildText(n, "string-date")
+	for _, a := range n.Attr {
+		if a.Key == "pub-type" || a.Key == "date-type" {
+			date.Type = a.Val
+		} else if a.Key == "publication-format" {
+			date.PublicationFormat = a.Val
+		} else if a.Key == "iso-8601-date" {
+			date.Iso8601 = a.Val
+		}
+	}
+	date.Time, date.Precision = date.ToTime()
+	return
+}
+
+func (this *Date) ToTime() (t time.Time, precision string) {
+	//iso-8601-date is authoritative when present, otherwise use the parts, then string-date
+	if this.Iso8601 != "" {
+		for _, layout := range []struct{ layout, precision string }{
+			{"2006-01-02", DatePrecisionDay},
+			{"2006-01", DatePrecisionMonth},
+			{"2006", DatePrecisionYear},
+		} {
+			t, err := time.Parse(layout.layout, this.Iso8601)
+			if err == nil {
+				return t, layout.precision
+			}
+		}
+	}
+
+	year, err := strconv.Atoi(this.Year)
+	if err != nil || year <= 0 {
+		return this.parseStringDate()
+	}
+	t = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	precision = DatePrecisionYear
+
+	month := ParseMonth(this.Month)
+	if month == 0 && this.Season != "" {
+		month = ParseMonth(this.Season)
+		if month != 0 {
+			return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), DatePrecisionSeason
+		}
+	}
+	if month == 0 {
+		return
+	}
+	t = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	precision = DatePrecisionMonth
+
+	day, err := strconv.Atoi(this.Day)
+	if err != nil || day < 1 || day > 31 {
+		return
+	}
+	//time.Date would move February 31 into March
+	if d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC); d.Month() == month {
+		t, precision = d, DatePrecisionDay
+	}
+	return
+}
+
+func (this *Date) parseStringDate() (t time.Time, precision string) {
+	s := strings.Join(strings.Fields(strings.NewReplacer(".", " ", ",", " ").Replace(this.StringDate)), " ")
+	if s == "" {
+		return
+	}
+	for _, layout := range stringDateLayouts {
+		if d, err := time.Parse(layout.layout, s); err == nil {
+			return d, layout.precision
+		}
+	}
+	return
+}
+
+func (this *Date) Problem() string {
+	//what ParseDate had to drop from a date it could read, empty if nothing
+	if this.Day != "" && this.Precision == DatePrecisionMonth {
+		return "day " + this.Day + " is not a day of " + this.Time.Format("January 2006")
+	}
+	return ""
+}
+
+func ParseMonth(s string) time.Month {
+	//numeric months, month names and seasons such as "Spring" or "Jan-Feb"
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0
+	}
+	if m, err := strconv.Atoi(s); err == nil {
+		if m < 1 || m > 12 {
+			return 0
+		}
+		return time.Month(m)
+	}
+	for name, m := range seasons {
+		if strings.HasPrefix(s, name) {
+			return m
+		}
+	}
+	for i, name := range months {
+		if strings.HasPrefix(s, name) {
+			return time.Month(i + 1)
+		}
+	}
+	return 0
+}
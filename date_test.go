@@ -0,0 +1,104 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+func TestDateToTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		date      Date
+		time      string
+		precision string
+	}{
+		{"day", Date{Day: "3", Month: "4", Year: "2020"}, "2020-04-03", DatePrecisionDay},
+		{"month name", Date{Month: "Apr", Year: "2020"}, "2020-04-01", DatePrecisionMonth},
+		{"year", Date{Year: "2020"}, "2020-01-01", DatePrecisionYear},
+		{"season", Date{Season: "Summer", Year: "2020"}, "2020-06-01", DatePrecisionSeason},
+		{"leap day", Date{Day: "29", Month: "2", Year: "2020"}, "2020-02-29", DatePrecisionDay},
+		{"day past the month", Date{Day: "31", Month: "2", Year: "2020"}, "2020-02-01", DatePrecisionMonth},
+		{"day past a short month", Date{Day: "31", Month: "4", Year: "2021"}, "2021-04-01", DatePrecisionMonth},
+		{"day out of range", Date{Day: "32", Month: "1", Year: "2020"}, "2020-01-01", DatePrecisionMonth},
+		{"iso date", Date{Iso8601: "2020-04-03", Day: "1", Month: "1", Year: "2019"}, "2020-04-03", DatePrecisionDay},
+		{"iso month", Date{Iso8601: "2020-04"}, "2020-04-01", DatePrecisionMonth},
+		{"invalid iso date", Date{Iso8601: "2020-02-31", Month: "2", Year: "2020"}, "2020-02-01", DatePrecisionMonth},
+		{"string date", Date{StringDate: "March 3, 2020"}, "2020-03-03", DatePrecisionDay},
+		{"string date, day first", Date{StringDate: "3 Mar. 2020"}, "2020-03-03", DatePrecisionDay},
+		{"string month", Date{StringDate: "March 2020"}, "2020-03-01", DatePrecisionMonth},
+		{"parts before string date", Date{Year: "2019", StringDate: "March 3, 2020"}, "2019-01-01", DatePrecisionYear},
+		{"no year", Date{Day: "3", Month: "4"}, "", DatePrecisionNone},
+		{"unreadable string date", Date{StringDate: "sometime"}, "", DatePrecisionNone},
+	}
+	for _, test := range tests {
+		got, precision := test.date.ToTime()
+		formatted := ""
+		if !got.IsZero() {
+			formatted = got.Format("2006-01-02")
+		}
+		if formatted != test.time || precision != test.precision {
+			t.Errorf("%s: %q %q, want %q %q", test.name, formatted, precision, test.time, test.precision)
+		}
+	}
+}
+
+func TestParseMonth(t *testing.T) {
+	tests := []struct {
+		month string
+		want  time.Month
+	}{
+		{"1", time.January},
+		{"09", time.September},
+		{"12", time.December},
+		{"0", 0},
+		{"13", 0},
+		{"Jan", time.January},
+		{"january", time.January},
+		{" Sept ", time.September},
+		{"Jan-Feb", time.January},
+		{"Spring", time.March},
+		{"Autumn", time.September},
+		{"Winter", time.December},
+		{"", 0},
+		{"later", 0},
+	}
+	for _, test := range tests {
+		if got := ParseMonth(test.month); got != test.want {
+			t.Errorf("ParseMonth(%q) = %v, want %v", test.month, got, test.want)
+		}
+	}
+}
+
+func TestDayPastTheMonthWarns(t *testing.T) {
+	src := `<article><front><article-meta>` +
+		`<pub-date pub-type="epub"><day>31</day><month>2</month><year>2020</year></pub-date>` +
+		`<history><date date-type="received"><day>30</day><month>2</month><year>2019</year></date></history>` +
+		`</article-meta></front></article>`
+	doc, err := html.Parse(bytes.NewReader(ArticlePrepareXml([]byte(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	article, report, err := ArticleParse(doc, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Epub.Precision != DatePrecisionMonth || article.Epub.Time.Format("2006-01-02") != "2020-02-01" {
+		t.Errorf("epub %s %q, want 2020-02-01 with month precision", article.Epub.Time.Format("2006-01-02"), article.Epub.Precision)
+	}
+	warned := map[string]bool{}
+	for _, d := range report.Warnings() {
+		if strings.Contains(d.Message, "is not a day of") {
+			warned[d.Path] = true
+		}
+	}
+	for _, path := range []string{"article/front/article-meta/pub-date", "article/front/article-meta/history/date"} {
+		if !warned[path] {
+			t.Errorf("no warning about the day at %s: %v", path, report.Diagnostics)
+		}
+	}
+}
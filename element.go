@@ -0,0 +1,191 @@
+package models
+
+import (
+	"sort"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"nofe/db"
+)
+
+const (
+	ElementMap    = "map"    //render as Tag
+	ElementUnwrap = "unwrap" //render only the children
+	ElementDrop   = "drop"   //render nothing
+	ElementKeep   = "keep"   //render with the jats name, for names that are already html
+)
+
+type ElementPolicy struct {
+	Action    string
+	Tag       string //only for ElementMap
+	Class     string
+	Transform ElementTransform //adjusts the tag and attributes before rendering, see Renderer
+	Render    ElementRender    //renders the whole element instead
+}
+
+// elements without an entry are unwrapped, so unknown jats never reaches the page as a tag
+var DefaultElementPolicy = ElementPolicy{Action: ElementUnwrap}
+
+var ElementPolicies = map[string]ElementPolicy{
+	//structure, frontend classes and props come from the Renderer
+	"title":          {Action: ElementMap, Tag: "h2"},
+	"sec":            {Action: ElementMap, Tag: "div"},
+	"p":              {Action: ElementMap, Tag: "div"},
+	"xref":           {Action: ElementMap, Tag: "a"},
+	"ext-link":       {Action: ElementMap, Tag: "a"},
+	"inline-formula": {Action: ElementMap, Tag: "span"},
+	"disp-formula":   {Action: ElementMap, Tag: "div"},
+	"inline-graphic": {Action: ElementMap, Tag: "img"},
+	"graphic":        {Action: ElementMap, Tag: "img"},
+	"fig":            {Action: ElementKeep},
+
+	//inline formatting
+	"italic":    {Action: ElementMap, Tag: "i"},
+	"bold":      {Action: ElementMap, Tag: "b"},
+	"underline": {Action: ElementMap, Tag: "u"},
+	"strike":    {Action: ElementMap, Tag: "s"},
+	"monospace": {Action: ElementMap, Tag: "code"},
+	"sc":        {Action: ElementMap, Tag: "span", Class: "small-caps"},
+	"overline":  {Action: ElementMap, Tag: "span", Class: "overline"},
+	"sub":       {Action: ElementKeep},
+	"sup":       {Action: ElementKeep},
+	"break":     {Action: ElementMap, Tag: "br"},
+	"label":     {Action: ElementMap, Tag: "span", Class: "label"},
+
+	//blocks
+	"list":                   {Action: ElementMap, Tag: "ul"},
+	"list-item":              {Action: ElementMap, Tag: "li"},
+	"def-list":               {Action: ElementMap, Tag: "dl"},
+	"term":                   {Action: ElementMap, Tag: "dt"},
+	"def":                    {Action: ElementMap, Tag: "dd"},
+	"disp-quote":             {Action: ElementMap, Tag: "blockquote"},
+	"preformat":              {Action: ElementMap, Tag: "pre"},
+	"code":                   {Action: ElementMap, Tag: "pre"},
+	"caption":                {Action: ElementMap, Tag: "div", Class: "caption"},
+	"boxed-text":             {Action: ElementMap, Tag: "div", Class: "boxed-text"},
+	"statement":              {Action: ElementMap, Tag: "div", Class: "statement"},
+	"supplementary-material": {Action: ElementMap, Tag: "div", Class: "supplementary-material"},
+	"fn-group":               {Action: ElementMap, Tag: "div", Class: "fn-group"},
+	"fn":                     {Action: ElementMap, Tag: "div", Class: "fn"},
+	"notes":                  {Action: ElementMap, Tag: "div", Class: "notes"},
+	"app-group":              {Action: ElementMap, Tag: "div", Class: "app-group"},
+	"app":                    {Action: ElementMap, Tag: "div", Class: "app"},
+	"glossary":               {Action: ElementMap, Tag: "div", Class: "glossary"},
+
+	//tables use the xhtml model
+	"table-wrap":      {Action: ElementMap, Tag: "div", Class: "table-wrap"},
+	"table-wrap-foot": {Action: ElementMap, Tag: "div", Class: "table-wrap-foot"},
+	"table":           {Action: ElementKeep},
+	"thead":           {Action: ElementKeep},
+	"tbody":           {Action: ElementKeep},
+	"tfoot":           {Action: ElementKeep},
+	"tr":              {Action: ElementKeep},
+	"th":              {Action: ElementKeep},
+	"td":              {Action: ElementKeep},
+	"col":             {Action: ElementKeep},
+	"colgroup":        {Action: ElementKeep},
+	"hr":              {Action: ElementKeep},
+
+	//wrappers whose text belongs in the flow
+	"named-content":  {Action: ElementUnwrap},
+	"styled-content": {Action: ElementUnwrap},
+	"alternatives":   {Action: ElementUnwrap},
+	"abbrev":         {Action: ElementUnwrap},
+	"email":          {Action: ElementUnwrap},
+	"uri":            {Action: ElementUnwrap},
+	"private-char":   {Action: ElementUnwrap},
+	"x":              {Action: ElementUnwrap},
+
+	//metadata with no place in the reading view
+	"object-id":       {Action: ElementDrop},
+	"alt-text":        {Action: ElementDrop},
+	"long-desc":       {Action: ElementDrop},
+	"permissions":     {Action: ElementDrop},
+	"attrib":          {Action: ElementDrop},
+	"processing-meta": {Action: ElementDrop},
+}
+
+// children of <back> kept in Article.Back, besides ack and ref-list which have fields of their own
+var BackMatterElements = map[string]bool{
+	"sec": true, "notes": true, "fn-group": true, "app-group": true, "glossary": true,
+}
+
+// elements that flow inside a sentence; everything else is a block with sentences of its own
+var InlineElements = map[string]bool{
+	"italic": true, "bold": true, "underline": true, "strike": true, "overline": true,
+	"monospace": true, "sc": true, "sub": true, "sup": true, "xref": true, "ext-link": true,
+	"named-content": true, "styled-content": true, "inline-formula": true, "inline-graphic": true,
+	"abbrev": true, "email": true, "uri": true, "private-char": true, "x": true, "break": true,
+}
+
+func IsInlineTag(tag string) bool {
+	return InlineElements[tag]
+}
+
+type ElementCount struct {
+	Element string `bson:"element" json:"element"`
+	Count   int    `bson:"count" json:"count"`
+}
+
+func ArticleElementPolicy(element string) ElementPolicy {
+	policy, found := ElementPolicies[element]
+	if !found {
+		return DefaultElementPolicy
+	}
+	return policy
+}
+
+func (this *Article) Coverage(coverage map[string]int) {
+	//count rendered elements that have no policy of their own, Abstract is already among Abstracts
+	nodes := [][]Node{this.Body, this.Ack, this.Back, this.Aff.Children}
+	for _, abstract := range this.Abstracts {
+		nodes = append(nodes, abstract.Children)
+	}
+	for _, note := range this.AuthorNotes {
+		nodes = append(nodes, note.Children)
+	}
+	for _, n := range nodes {
+		nodesCoverage(n, coverage)
+	}
+	for i := range this.SubArticles {
+		this.SubArticles[i].Coverage(coverage)
+	}
+}
+
+func nodesCoverage(nodes []Node, coverage map[string]int) {
+	for _, node := range nodes {
+		if node.Type != "tag" || IsMathTag(node.Tag) || node.Tag == "tex-math" {
+			continue
+		}
+		if _, found := ElementPolicies[node.Tag]; !found {
+			coverage[node.Tag]++
+		}
+		nodesCoverage(node.Children, coverage)
+	}
+}
+
+func ArticleCoverage(query bson.M) (counts []ElementCount, err error) {
+	//unmapped elements across the stored articles matching query, most frequent first
+	coverage := map[string]int{}
+	article := Article{}
+	iter := db.GetCol("articles").Find(query).Iter()
+	for iter.Next(&article) {
+		article.Coverage(coverage)
+		article = Article{}
+	}
+	err = iter.Close()
+	if err != nil {
+		return
+	}
+
+	for element, count := range coverage {
+		counts = append(counts, ElementCount{Element: element, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Element < counts[j].Element
+	})
+	return
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"nofe/db"
+)
+
+// how long to wait before fetching a withheld article with no release date again
+var WithheldRetry = 30 * 24 * time.Hour
+
+// how often to fetch an article without full text and release date before giving up on it
+var WithheldRetries = 6
+
+// article types that usually come without a body, so a missing body doesn't mean it is withheld
+var BodylessArticleTypes = map[string]bool{
+	"abstract":              true,
+	"addendum":              true,
+	"correction":            true,
+	"expression-of-concern": true,
+	"removal":               true,
+	"retraction":            true,
+}
+
+func (this *Article) CheckEmbargo(now time.Time) {
+	//an article is withheld until pmc-release, or while efetch returns no full text
+	this.Withheld = false
+	this.ReimportAt = time.Time{}
+
+	release := this.PmcRelease.Time
+	if !release.IsZero() && release.After(now) {
+		this.Withheld = true
+		//the day after, so the release has been published by then
+		this.ReimportAt = release.Add(24 * time.Hour)
+		return
+	}
+
+	if len(this.Body) == 0 && len(this.SubArticles) == 0 && !BodylessArticleTypes[this.Type] {
+		this.Withheld = true
+		this.ReimportAt = now.Add(WithheldRetry)
+	}
+}
+
+func (this *Article) CountReimport(attempts int) {
+	//attempts is how often the article was fetched without full text before; stop after WithheldRetries
+	release := this.PmcRelease.Time
+	if !this.Withheld || (!release.IsZero() && release.After(time.Now())) {
+		this.ReimportAttempts = 0
+		return
+	}
+	this.ReimportAttempts = attempts + 1
+	if this.ReimportAttempts >= WithheldRetries {
+		this.ReimportAt = time.Time{}
+	}
+}
+
+func ArticleReimport(article Article) (updated Article, err error) {
+	//fetch again and replace the stored copy, keeping its id and import details
+	updated, err = ArticleFetchByPmc(article.Pmc)
+	if err != nil {
+		return
+	}
+	if updated.Pmc == "" {
+		//nothing usable came back, try again later
+		article.ReimportAt = time.Now().Add(WithheldRetry)
+		article.CountReimport(article.ReimportAttempts)
+		set := bson.M{"reimportAttempts": article.ReimportAttempts}
+		update := bson.M{"$set": set}
+		if article.ReimportAt.IsZero() {
+			update["$unset"] = bson.M{"reimportAt": ""}
+		} else {
+			set["reimportAt"] = article.ReimportAt
+		}
+		err = db.GetCol("articles").UpdateId(article.Id, update)
+		return article, err
+	}
+
+	updated.Id = article.Id
+	updated.ImportedBy = article.ImportedBy
+	updated.ImportedDate = article.ImportedDate
+	updated.CountReimport(article.ReimportAttempts)
+	err = updated.LinkRelated()
+	if err != nil {
+		return
+	}
+
+	err = db.GetCol("articles").UpdateId(article.Id, updated)
+	if err != nil {
+		return
+	}
+
+	err = AnnotationReanchorArticle(&updated)
+	return
+}
+
+func ArticleReimportDue(now time.Time) (err error) {
+	query := bson.M{
+		"withheld": true,
+		"reimportAt": bson.M{
+			"$lte": now,
+		},
+	}
+
+	var articles []Article
+	err = db.GetCol("articles").Find(query).All(&articles)
+	if err != nil {
+		return
+	}
+
+	//one failure doesn't stop the others, the error lists them all
+	failed := []string{}
+	for _, article := range articles {
+		log.Println("reimport pmc:", article.Pmc)
+		_, err = ArticleReimport(article)
+		if err != nil {
+			failed = append(failed, article.Pmc+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New("reimport failed for " + strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func ArticleReimportLoop(interval time.Duration) {
+	//run in its own goroutine
+	for {
+		err := ArticleReimportDue(time.Now())
+		if err != nil {
+			log.Println("reimport:", err)
+		}
+		time.Sleep(interval)
+	}
+}
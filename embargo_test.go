@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckEmbargo(t *testing.T) {
+	now := time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)
+	body := []Node{{Type: "tag", Tag: "sec"}}
+	tests := []struct {
+		name     string
+		article  Article
+		withheld bool
+		reimport time.Time
+	}{
+		{"full text", Article{Type: "research-article", Body: body}, false, time.Time{}},
+		{"no body", Article{Type: "research-article"}, true, now.Add(WithheldRetry)},
+		{"retraction notice", Article{Type: "retraction"}, false, time.Time{}},
+		{"abstract only record", Article{Type: "abstract"}, false, time.Time{}},
+		{"embargoed", Article{Type: "research-article", Body: body, PmcRelease: Date{Time: now.Add(48 * time.Hour)}}, true, now.Add(72 * time.Hour)},
+	}
+	for _, test := range tests {
+		test.article.CheckEmbargo(now)
+		if test.article.Withheld != test.withheld || !test.article.ReimportAt.Equal(test.reimport) {
+			t.Errorf("%s: withheld %v, reimport at %v, want %v, %v", test.name, test.article.Withheld, test.article.ReimportAt, test.withheld, test.reimport)
+		}
+	}
+}
+
+func TestCountReimport(t *testing.T) {
+	article := Article{Type: "research-article"}
+	for attempt := 0; attempt < WithheldRetries; attempt++ {
+		article.CheckEmbargo(time.Now())
+		article.CountReimport(article.ReimportAttempts)
+		if article.ReimportAttempts != attempt+1 {
+			t.Fatalf("attempt %d counted as %d", attempt+1, article.ReimportAttempts)
+		}
+		if last := attempt+1 == WithheldRetries; article.ReimportAt.IsZero() != last {
+			t.Errorf("attempt %d: reimport at %v", attempt+1, article.ReimportAt)
+		}
+	}
+
+	//the full text arrived
+	article.Body = []Node{{Type: "tag", Tag: "sec"}}
+	article.CheckEmbargo(time.Now())
+	article.CountReimport(article.ReimportAttempts)
+	if article.ReimportAttempts != 0 || !article.ReimportAt.IsZero() {
+		t.Errorf("article with full text: %d attempts, reimport at %v", article.ReimportAttempts, article.ReimportAt)
+	}
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const mathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+
+var (
+	selfClosingTag = regexp.MustCompile(`<([A-Za-z][\w:.-]*)((?:\s+[^\s=/>]+(?:\s*=\s*(?:"[^"]*"|'[^']*'))?)*)\s*/>`)
+	cdataSection   = regexp.MustCompile(`(?s)<!\[CDATA\[(.*?)\]\]>`)
+	texDocument    = regexp.MustCompile(`(?s)\\begin\{document\}(.*)\\end\{document\}`)
+	renamedTag     = regexp.MustCompile(`<(/?)(title|caption)([\s/>])`)
+)
+
+// jats elements the html parser would mangle, renamed by ArticlePrepareXml: it reads <title> as raw text
+// and drops <caption> outside a table
+var renamedTags = map[string]string{
+	"title":   "jats-title",
+	"caption": "jats-caption",
+}
+
+// html void elements, which the html parser already closes itself
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+func ArticlePrepareXml(body []byte) []byte {
+	//<title> is raw text in html, so <italic> inside a section title would stay literal text,
+	//and <caption> in a <fig> would be dropped, leaving its title and paragraphs loose
+	body = renamedTag.ReplaceAllFunc(body, func(tag []byte) []byte {
+		m := renamedTag.FindSubmatch(tag)
+		return []byte("<" + string(m[1]) + renamedTags[string(m[2])] + string(m[3]))
+	})
+	//the html parser ignores "/>" on unknown tags, so <mml:mspace/> or <xref/> would swallow
+	//their following siblings, and it turns CDATA (used by tex-math) into comments
+	body = selfClosingTag.ReplaceAllFunc(body, func(tag []byte) []byte {
+		m := selfClosingTag.FindSubmatch(tag)
+		name := string(m[1])
+		if voidTags[strings.ToLower(name)] {
+			return tag
+		}
+		return []byte("<" + name + string(m[2]) + "></" + name + ">")
+	})
+	body = cdataSection.ReplaceAllFunc(body, func(cdata []byte) []byte {
+		m := cdataSection.FindSubmatch(cdata)
+		return []byte(html.EscapeString(string(m[1])))
+	})
+	return body
+}
+
+// presentation MathML, anything else inside a formula is unwrapped when serializing
+var MathMLElements = map[string]bool{
+	"math": true, "mi": true, "mn": true, "mo": true, "mtext": true, "mspace": true, "ms": true,
+	"mrow": true, "mfrac": true, "msqrt": true, "mroot": true, "mstyle": true, "merror": true, "mpadded": true,
+	"mphantom": true, "mfenced": true, "menclose": true, "msub": true, "msup": true, "msubsup": true,
+	"munder": true, "mover": true, "munderover": true, "mmultiscripts": true, "mprescripts": true, "none": true,
+	"mtable": true, "mtr": true, "mtd": true, "mlabeledtr": true, "maligngroup": true, "malignmark": true,
+	"semantics": true, "annotation": true, "mglyph": true,
+}
+
+func JatsName(n *html.Node) string {
+	//element name as in the jats source, undoing the renames of ArticlePrepareXml
+	for name, renamed := range renamedTags {
+		if n.Data == renamed {
+			return name
+		}
+	}
+	return n.Data
+}
+
+func IsMathTag(tag string) bool {
+	return tag == "mml:math" || tag == "math"
+}
+
+func IsFormulaTag(tag string) bool {
+	return tag == "inline-formula" || tag == "disp-formula"
+}
+
+func ParseMathML(n *html.Node) (mathml string) {
+	//serialize with the mml: prefix dropped so browsers recognise it as MathML
+	if n.Type == html.TextNode {
+		return html.EscapeString(n.Data)
+	}
+	if n.Type != html.ElementNode {
+		return
+	}
+
+	tag := strings.TrimPrefix(n.Data, "mml:")
+	if !MathMLElements[tag] {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			mathml += ParseMathML(c)
+		}
+		return
+	}
+	mathml = "<" + tag
+	if tag == "math" {
+		mathml += " xmlns=\"" + mathMLNamespace + "\""
+	}
+	for _, a := range n.Attr {
+		if a.Key == "xmlns" || strings.HasPrefix(a.Key, "xmlns:") || a.Namespace == "xmlns" {
+			continue
+		}
+		key := strings.TrimPrefix(a.Key, "mml:")
+		if !attributeName.MatchString(key) || IsEventAttribute(key) {
+			continue
+		}
+		if _, ok := SafeUrl(a.Val); UrlAttributes[key] && !ok {
+			continue
+		}
+		mathml += " " + key + "=\"" + html.EscapeString(a.Val) + "\""
+	}
+	mathml += ">"
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		mathml += ParseMathML(c)
+	}
+	mathml += "</" + tag + ">"
+	return
+}
+
+func SanitizeMathML(mathml string) (safe string) {
+	//stored MathML may not have come through ParseMathML, e.g. from the db or DecodeJSON, so filter it again
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(mathml), context)
+	if err != nil {
+		return
+	}
+	for _, n := range nodes {
+		safe += ParseMathML(n)
+	}
+	return
+}
+
+func (this *Node) Formula() (mathml string, tex string) {
+	//first MathML and TeX representations found under a formula node
+	for _, child := range this.Children {
+		if child.MathML != "" && mathml == "" {
+			mathml = child.MathML
+		}
+		if child.Tex != "" && tex == "" {
+			tex = child.Tex
+		}
+		if child.Type == "tag" && (mathml == "" || tex == "") {
+			m, t := child.Formula()
+			if mathml == "" {
+				mathml = m
+			}
+			if tex == "" {
+				tex = t
+			}
+		}
+	}
+	return
+}
+
+func TexMathBody(tex string) string {
+	//tex-math often holds a whole LaTeX document; keep only the equation
+	if m := texDocument.FindStringSubmatch(tex); m != nil {
+		tex = m[1]
+	}
+	tex = strings.TrimSpace(tex)
+	for _, delim := range []string{"$$", "$"} {
+		if len(tex) > 2*len(delim) && strings.HasPrefix(tex, delim) && strings.HasSuffix(tex, delim) {
+			tex = strings.TrimSpace(tex[len(delim) : len(tex)-len(delim)])
+			break
+		}
+	}
+	return tex
+}
+
+func ArticleRenderMath(mathml string, tex string, display bool) (output string) {
+	if mathml == "" {
+		if tex == "" {
+			return
+		}
+		//no MathML to annotate, leave the TeX for a client side typesetter
+		if display {
+			return "<span class=\"tex-math\">\\[" + html.EscapeString(TexMathBody(tex)) + "\\]</span>"
+		}
+		return "<span class=\"tex-math\">\\(" + html.EscapeString(TexMathBody(tex)) + "\\)</span>"
+	}
+
+	mathml = SanitizeMathML(mathml)
+	if !strings.HasPrefix(mathml, "<math") {
+		return
+	}
+	open := strings.Index(mathml, ">")
+	end := strings.LastIndex(mathml, "</math>")
+	if open == -1 || end < open {
+		return
+	}
+	inner := mathml[open+1 : end]
+
+	output = "<math xmlns=\"" + mathMLNamespace + "\""
+	if display {
+		output += " display=\"block\""
+	}
+	output += ">"
+	if tex != "" {
+		output += "<semantics><mrow>" + inner + "</mrow>"
+		output += "<annotation encoding=\"application/x-tex\">" + html.EscapeString(TexMathBody(tex)) + "</annotation>"
+		output += "</semantics>"
+	} else {
+		output += inner
+	}
+	output += "</math>"
+	return
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMathSanitizesStoredMathML(t *testing.T) {
+	tests := []struct {
+		mathml string
+		output string
+	}{
+		{`<math xmlns="http://www.w3.org/1998/Math/MathML"><mi>x</mi></math>`, `<math xmlns="http://www.w3.org/1998/Math/MathML"><mi>x</mi></math>`},
+		{`<math><script>alert(1)</script></math>`, `<math xmlns="http://www.w3.org/1998/Math/MathML">alert(1)</math>`},
+		{`<math><mi onclick="alert(1)">x</mi></math>`, `<math xmlns="http://www.w3.org/1998/Math/MathML"><mi>x</mi></math>`},
+		{`<math><mi href="javascript:alert(1)">x</mi></math>`, `<math xmlns="http://www.w3.org/1998/Math/MathML"><mi>x</mi></math>`},
+		{`<img src=x onerror="alert(1)">`, ``},
+	}
+	for _, test := range tests {
+		if output := ArticleRenderMath(test.mathml, "", false); output != test.output {
+			t.Errorf("%s: %q, want %q", test.mathml, output, test.output)
+		}
+	}
+
+	node := Node{Type: "tag", Tag: "inline-formula", Children: []Node{{Type: "tag", Tag: "mml:math", MathML: `<math><script>alert(1)</script></math>`}}}
+	output := DefaultRenderer.renderNodesAnnotated([]Node{node}, 0, nil, "1", nil)
+	if strings.Contains(output, "<script") {
+		t.Errorf("script rendered from stored MathML: %s", output)
+	}
+}
+
+func TestArticlePrepareXml(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		want string
+	}{
+		{"self-closing mathml", `<mml:mrow><mml:mi>a</mml:mi><mml:mspace width="1em"/><mml:mi>b</mml:mi></mml:mrow>`, `<mml:mrow><mml:mi>a</mml:mi><mml:mspace width="1em"></mml:mspace><mml:mi>b</mml:mi></mml:mrow>`},
+		{"self-closing xref", `see <xref ref-type="bibr" rid="r1"/> and`, `see <xref ref-type="bibr" rid="r1"></xref> and`},
+		{"void html element kept", `a<br/>b`, `a<br/>b`},
+		{"cdata", `<tex-math><![CDATA[$a<b$]]></tex-math>`, `<tex-math>$a&lt;b$</tex-math>`},
+		{"title", `<title>A <italic>b</italic></title>`, `<jats-title>A <italic>b</italic></jats-title>`},
+		{"caption", `<caption><title>T</title></caption>`, `<jats-caption><jats-title>T</jats-title></jats-caption>`},
+		{"title-group untouched", `<title-group><article-title>A</article-title></title-group>`, `<title-group><article-title>A</article-title></title-group>`},
+	}
+	for _, test := range tests {
+		if got := string(ArticlePrepareXml([]byte(test.xml))); got != test.want {
+			t.Errorf("%s: %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseFormula(t *testing.T) {
+	article := parseArticle(t, `<article><body><sec><p>Where <inline-formula><mml:math><mml:mi>a</mml:mi><mml:mspace width="1em"/><mml:mo>&lt;</mml:mo><mml:mi onclick="x()">b</mml:mi></mml:math></inline-formula> holds.</p>`+
+		`<disp-formula id="e1"><label>(1)</label><alternatives>`+
+		`<mml:math><mml:mi>x</mml:mi></mml:math>`+
+		`<tex-math><![CDATA[\documentclass{article}\usepackage{amsmath}\begin{document}$$x<y$$\end{document}]]></tex-math>`+
+		`</alternatives></disp-formula></sec></body></article>`)
+
+	p := article.Body[0].Children[0]
+	inline, _ := p.Children[1].Formula()
+	want := `<math xmlns="http://www.w3.org/1998/Math/MathML"><mi>a</mi><mspace width="1em"></mspace><mo>&lt;</mo><mi>b</mi></math>`
+	if inline != want {
+		t.Errorf("inline MathML %q, want %q", inline, want)
+	}
+	if text := NodesText(p.Children); !strings.HasSuffix(text, " holds.") {
+		t.Errorf("text after the formula lost: %q", text)
+	}
+
+	disp := article.Body[0].Children[1]
+	mathml, tex := disp.Formula()
+	if body := TexMathBody(tex); body != "x<y" {
+		t.Errorf("tex body %q, want %q", body, "x<y")
+	}
+	output := ArticleRenderMath(mathml, tex, true)
+	want = `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><mrow><mi>x</mi></mrow>` +
+		`<annotation encoding="application/x-tex">x&lt;y</annotation></semantics></math>`
+	if output != want {
+		t.Errorf("rendered %q, want %q", output, want)
+	}
+	if tex := ArticleRenderMath("", `$a<b$`, false); tex != `<span class="tex-math">\(a&lt;b\)</span>` {
+		t.Errorf("tex fallback %q", tex)
+	}
+}
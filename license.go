@@ -0,0 +1,127 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	ccLicenseUrl   = regexp.MustCompile(`(?i)creativecommons\.org/licenses/([a-z-]+)/(\d+(?:\.\d+)?)`)
+	ccZeroUrl      = regexp.MustCompile(`(?i)creativecommons\.org/publicdomain/zero/(\d+(?:\.\d+)?)`)
+	ccMarkUrl      = regexp.MustCompile(`(?i)creativecommons\.org/publicdomain/mark/`)
+	ccAbbreviation = regexp.MustCompile(`(?i)\bCC[ -]?(BY(?:[ -](?:NC|ND|SA))*)\b(?:[ -]?(\d\.\d))?`)
+	ccZero         = regexp.MustCompile(`(?i)\bCC0\b|creative commons zero|public domain dedication`)
+	licenseVersion = regexp.MustCompile(`\b(\d\.\d)\b`)
+	ccAttribution  = regexp.MustCompile(`(?i)creative commons attribution`)
+	spdxVersion    = regexp.MustCompile(`-\d+(?:\.\d+)*$`)
+)
+
+type License struct {
+	Type string `bson:"type,omitempty" json:"type,omitempty"` //license-type attribute
+	Url  string `bson:"url,omitempty" json:"url,omitempty"`   //xlink:href, ali:license_ref or a link in the text
+	Text string `bson:"text,omitempty" json:"text,omitempty"` //license-p text
+	Spdx string `bson:"spdx,omitempty" json:"spdx,omitempty"` //normalized identifier, e.g. CC-BY-4.0
+}
+
+func (this *Article) ParseLicense(n *html.Node) (license License) {
+	for _, a := range n.Attr {
+		if a.Key == "license-type" {
+			license.Type = a.Val
+		} else if a.Key == "xlink:href" {
+			license.Url = strings.TrimSpace(a.Val)
+		}
+	}
+
+	license.ParseContent(n)
+
+	license.Spdx = LicenseSpdx(license.Url, license.Type, license.Text)
+	if license.Spdx == "" {
+		this.warn(n, "unrecognised license")
+	}
+	return
+}
+
+func (this *License) ParseContent(n *html.Node) {
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Data == "ali:license_ref" && this.Url == "" {
+			this.Url = ParseText(c)
+		} else if c.Data == "license-p" {
+			if this.Text != "" {
+				this.Text += " "
+			}
+			this.Text += ParseText(c)
+		} else if c.Data == "ext-link" && this.Url == "" {
+			for _, a := range c.Attr {
+				if a.Key == "xlink:href" && strings.Contains(a.Val, "creativecommons.org") {
+					this.Url = strings.TrimSpace(a.Val)
+				}
+			}
+		}
+		this.ParseContent(c)
+	}
+}
+
+func LicenseSpdx(url string, licenseType string, text string) string {
+	//the url is the most precise, then the license-type attribute, then the prose
+	if m := ccLicenseUrl.FindStringSubmatch(url); m != nil {
+		return "CC-" + strings.ToUpper(m[1]) + "-" + licenseFullVersion(m[2])
+	}
+	if m := ccZeroUrl.FindStringSubmatch(url); m != nil {
+		return "CC0-" + licenseFullVersion(m[1])
+	}
+	if ccMarkUrl.MatchString(url) {
+		return "PDM"
+	}
+
+	for _, s := range []string{licenseType, text} {
+		if s == "" {
+			continue
+		}
+		if ccZero.MatchString(s) {
+			return "CC0-1.0"
+		}
+		if m := ccAbbreviation.FindStringSubmatch(s); m != nil {
+			spdx := "CC-" + strings.ToUpper(strings.Replace(m[1], " ", "-", -1))
+			if m[2] != "" {
+				spdx += "-" + m[2]
+			}
+			return spdx
+		}
+		if ccAttribution.MatchString(s) {
+			lower := strings.ToLower(s)
+			spdx := "CC-BY"
+			if strings.Contains(lower, "noncommercial") || strings.Contains(lower, "non-commercial") {
+				spdx += "-NC"
+			}
+			if strings.Contains(lower, "noderiv") || strings.Contains(lower, "no derivative") {
+				spdx += "-ND"
+			}
+			if strings.Contains(lower, "sharealike") || strings.Contains(lower, "share alike") {
+				spdx += "-SA"
+			}
+			if m := licenseVersion.FindStringSubmatch(s); m != nil {
+				spdx += "-" + m[1]
+			}
+			return spdx
+		}
+	}
+	return ""
+}
+
+func LicenseFamily(spdx string) string {
+	//spdx without its version, e.g. CC-BY-NC-4.0 becomes CC-BY-NC
+	if m := spdxVersion.FindStringIndex(spdx); m != nil {
+		return spdx[:m[0]]
+	}
+	return spdx
+}
+
+func licenseFullVersion(version string) string {
+	if !strings.Contains(version, ".") {
+		return version + ".0"
+	}
+	return version
+}
@@ -0,0 +1,61 @@
+package models
+
+import "testing"
+
+func TestLicenseSpdx(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		licenseType string
+		text        string
+		spdx        string
+	}{
+		{"by url", "https://creativecommons.org/licenses/by/4.0/", "", "", "CC-BY-4.0"},
+		{"by url without minor version", "http://creativecommons.org/licenses/by/3/", "", "", "CC-BY-3.0"},
+		{"by url with minor version", "http://creativecommons.org/licenses/by/2.5/", "", "", "CC-BY-2.5"},
+		{"by-nc-nd url", "https://creativecommons.org/licenses/by-nc-nd/4.0/legalcode", "", "", "CC-BY-NC-ND-4.0"},
+		{"by-sa url without scheme", "creativecommons.org/licenses/by-sa/4.0", "", "", "CC-BY-SA-4.0"},
+		{"cc0 url", "https://creativecommons.org/publicdomain/zero/1.0/", "", "", "CC0-1.0"},
+		{"public domain mark url", "https://creativecommons.org/publicdomain/mark/1.0/", "", "", "PDM"},
+		{"url wins over text", "https://creativecommons.org/licenses/by-nc/4.0/", "open-access", "Creative Commons Attribution License", "CC-BY-NC-4.0"},
+		{"abbreviation", "", "", "Distributed under CC BY-NC-ND 4.0.", "CC-BY-NC-ND-4.0"},
+		{"abbreviation without version", "", "", "Licensed CC BY.", "CC-BY"},
+		{"abbreviation in license-type", "", "cc-by", "", "CC-BY"},
+		{"attribution prose", "", "", "This is an open access article distributed under the terms of the Creative Commons Attribution License.", "CC-BY"},
+		{"noncommercial prose", "", "", "Creative Commons Attribution-NonCommercial 4.0 International License", "CC-BY-NC-4.0"},
+		{"noncommercial no derivatives prose", "", "", "the Creative Commons Attribution-NonCommercial-NoDerivatives License", "CC-BY-NC-ND"},
+		{"sharealike prose", "", "", "Creative Commons Attribution-ShareAlike 3.0 Unported", "CC-BY-SA-3.0"},
+		{"cc0 prose", "", "", "waived under the Creative Commons CC0 public domain dedication", "CC0-1.0"},
+		{"unknown", "https://example.org/license", "", "All rights reserved.", ""},
+	}
+	for _, test := range tests {
+		if spdx := LicenseSpdx(test.url, test.licenseType, test.text); spdx != test.spdx {
+			t.Errorf("%s: %q, want %q", test.name, spdx, test.spdx)
+		}
+	}
+}
+
+func TestParseLicenseContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		license string
+		url     string
+		spdx    string
+	}{
+		{"xlink:href", `<license xlink:href="https://creativecommons.org/licenses/by/4.0/"><license-p>Open.</license-p></license>`, "https://creativecommons.org/licenses/by/4.0/", "CC-BY-4.0"},
+		{"ali:license_ref", `<license><ali:license_ref>https://creativecommons.org/licenses/by-nc/4.0/</ali:license_ref><license-p>Some rights.</license-p></license>`, "https://creativecommons.org/licenses/by-nc/4.0/", "CC-BY-NC-4.0"},
+		{"ext-link in license-p", `<license><license-p>See <ext-link ext-link-type="uri" xlink:href="https://creativecommons.org/publicdomain/zero/1.0/">CC0</ext-link>.</license-p></license>`, "https://creativecommons.org/publicdomain/zero/1.0/", "CC0-1.0"},
+		{"other ext-link ignored", `<license><license-p>Terms at <ext-link xlink:href="https://example.org/terms">our site</ext-link>, under CC BY 4.0.</license-p></license>`, "", "CC-BY-4.0"},
+	}
+	for _, test := range tests {
+		article := parseArticle(t, `<article><front><article-meta><permissions>`+test.license+`</permissions></article-meta></front></article>`)
+		details := article.Permissions.LicenseDetails
+		if len(details) != 1 {
+			t.Errorf("%s: %d licenses, want 1", test.name, len(details))
+			continue
+		}
+		if details[0].Url != test.url || details[0].Spdx != test.spdx {
+			t.Errorf("%s: url %q spdx %q, want %q %q", test.name, details[0].Url, details[0].Spdx, test.url, test.spdx)
+		}
+	}
+}
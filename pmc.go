@@ -1,14 +1,13 @@
 package models
 
 import (
-	_ "bytes"
+	"bytes"
 	"encoding/json"
 	_ "fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -23,19 +22,27 @@ type Article struct {
 	ImportedBy   string        `bson:"importedBy,omitempty" json:"importedBy,omitempty"`
 	ImportedDate time.Time     `bson:"importedDate,omitempty" json:"importedDate,omitempty"`
 	Type         string        `bson:"type,omitempty" json:"type,omitempty"`
+	Lang         string        `bson:"lang,omitempty" json:"lang,omitempty"` //xml:lang of the article element
 	Journal      Journal       `bson:"journal,omitempty" json:"journal,omitempty"`
 	Pmid         string        `bson:"pmid,omitempty" json:"pmid,omitempty"`
 	Pmc          string        `bson:"pmc,omitempty" json:"pmc,omitempty"`
 	Doi          string        `bson:"doi,omitempty" json:"doi,omitempty"`
+	DoiKey       string        `bson:"doiKey,omitempty" json:"-"` //lowercased Doi, indexed for lookups
 	PublisherId  string        `bson:"publisherId,omitempty" json:"publisherId,omitempty"`
 	Categories   []Category    `bson:"categories,omitempty" json:"categories,omitempty"`
 	Titles       []string      `bson:"titles,omitempty" json:"titles,omitempty"`
+	Title        Title         `bson:"title,omitempty" json:"title,omitempty"`
+	AltTitles    []Title       `bson:"altTitles,omitempty" json:"altTitles,omitempty"`
+	TransTitles  []Title       `bson:"transTitles,omitempty" json:"transTitles,omitempty"`
 	Contributors []Contributor `bson:"contributors,omitempty" json:"contributors,omitempty"`
 	Aff          Aff           `bson:"aff,omitempty" json:"aff,omitempty"`
 	AuthorNotes  []AuthorNote  `bson:"authorNotes,omitempty" json:"authorNotes,omitempty"`
 	Ppub         Date          `bson:"ppub,omitempty" json:"ppub,omitempty"`
 	Epub         Date          `bson:"epub,omitempty" json:"epub,omitempty"`
 	PmcRelease   Date          `bson:"pmcRelease,omitempty" json:"pmcRelease,omitempty"`
+	Collection   Date          `bson:"collection,omitempty" json:"collection,omitempty"`
+	PubDates     []Date        `bson:"pubDates,omitempty" json:"pubDates,omitempty"`
+	PubDate      time.Time     `bson:"pubDate,omitempty" json:"pubDate,omitempty"` //epub, else ppub, else collection date; for sorting
 	Volume       string        `bson:"volume,omitempty" json:"volume,omitempty"`
 	Issue        string        `bson:"issue,omitempty" json:"issue,omitempty"`
 	Fpage        string        `bson:"fPage,omitempty" json:"fPage,omitempty"`
@@ -43,11 +50,27 @@ type Article struct {
 	History      History       `bson:"history,omitempty" json:"history,omitempty"`
 	Permissions  Permissions   `bson:"permissions,omitempty" json:"permissions,omitempty"`
 	Abstract     []Node        `bson:"abstract,omitempty" json:"abstract,omitempty"`
+	Abstracts    []Abstract    `bson:"abstracts,omitempty" json:"abstracts,omitempty"`
 	PageCount    int           `bson:"pageCount,omitempty" json:"pageCount,omitempty"`
+	Counts       Counts        `bson:"counts,omitempty" json:"counts,omitempty"` //declared in <counts>, or computed
 	Metas        []Meta        `bson:"metas,omitempty" json:"metas,omitempty"`
 	Body         []Node        `bson:"body,omitempty" json:"body,omitempty"`
 	Ack          []Node        `bson:"ack,omitempty" json:"ack,omitempty"`
+	Back         []Node        `bson:"back,omitempty" json:"back,omitempty"` //sec, notes and fn-group elements of <back>
 	Refs         Refs          `bson:"refs,omitempty" json:"refs,omitempty"`
+
+	RelatedArticles []RelatedArticle `bson:"relatedArticles,omitempty" json:"relatedArticles,omitempty"`
+	Status          string           `bson:"status,omitempty" json:"status,omitempty"` //retracted, expression-of-concern or corrected
+
+	XmlId       string    `bson:"xmlId,omitempty" json:"xmlId,omitempty"` //id attribute, only for sub-articles
+	SubArticles []Article `bson:"subArticles,omitempty" json:"subArticles,omitempty"`
+
+	Withheld         bool      `bson:"withheld,omitempty" json:"withheld,omitempty"`                 //embargoed, or the publisher withholds the full text
+	ReimportAt       time.Time `bson:"reimportAt,omitempty" json:"reimportAt,omitempty"`             //when to fetch a withheld article again
+	ReimportAttempts int       `bson:"reimportAttempts,omitempty" json:"reimportAttempts,omitempty"` //fetches that still had no full text
+
+	report *ParseReport //diagnostics while parsing, see ArticleParse
+	parsed bool         //an <article> element was found
 }
 
 type Journal struct {
@@ -65,6 +88,15 @@ type Category struct {
 	Subject string `bson:"subject,omitempty" json:"subject,omitempty"`
 }
 
+type Title struct {
+	Type             string `bson:"type,omitempty" json:"type,omitempty"` //alt-title-type, only for alt titles
+	Lang             string `bson:"lang,omitempty" json:"lang,omitempty"` //xml:lang, only for translated titles
+	Text             string `bson:"text,omitempty" json:"text,omitempty"`
+	Children         []Node `bson:"children,omitempty" json:"children,omitempty"`
+	Subtitle         string `bson:"subtitle,omitempty" json:"subtitle,omitempty"`
+	SubtitleChildren []Node `bson:"subtitleChildren,omitempty" json:"subtitleChildren,omitempty"`
+}
+
 type Contributor struct {
 	Type       string `bson:"type,omitempty" json:"type,omitempty"`
 	Surname    string `bson:"surname,omitempty" json:"surname,omitempty"`
@@ -82,22 +114,39 @@ type AuthorNote struct {
 	Children  []Node `bson:"children,omitempty" json:"children,omitempty"`
 }
 
+type Abstract struct {
+	Type       string `bson:"type,omitempty" json:"type,omitempty"` //abstract-type, empty for the main abstract
+	Lang       string `bson:"lang,omitempty" json:"lang,omitempty"` //xml:lang
+	Translated bool   `bson:"translated,omitempty" json:"translated,omitempty"`
+	Children   []Node `bson:"children,omitempty" json:"children,omitempty"`
+}
+
 type History struct {
-	Received Date `bson:"received,omitempty" json:"received,omitempty"`
-	RevRecd  Date `bson:"revRecd,omitempty" json:"revRecd,omitempty"`
-	Accepted Date `bson:"accepted,omitempty" json:"accepted,omitempty"`
+	Received Date   `bson:"received,omitempty" json:"received,omitempty"`
+	RevRecd  Date   `bson:"revRecd,omitempty" json:"revRecd,omitempty"`
+	Accepted Date   `bson:"accepted,omitempty" json:"accepted,omitempty"`
+	Dates    []Date `bson:"dates,omitempty" json:"dates,omitempty"` //every history date, including other date-types
 }
 
 type Permissions struct {
-	CopyrightStatement string   `bson:"copyrightStatement,omitempty" json:"copyrightStatement,omitempty"`
-	CopyrightYear      string   `bson:"copyrightYear,omitempty" json:"copyrightYear,omitempty"`
-	Licenses           []string `bson:"licenses,omitempty" json:"licenses,omitempty"`
+	CopyrightStatement string    `bson:"copyrightStatement,omitempty" json:"copyrightStatement,omitempty"`
+	CopyrightYear      string    `bson:"copyrightYear,omitempty" json:"copyrightYear,omitempty"`
+	Licenses           []string  `bson:"licenses,omitempty" json:"licenses,omitempty"` //license-type attributes
+	LicenseDetails     []License `bson:"licenseDetails,omitempty" json:"licenseDetails,omitempty"`
+	Spdx               string    `bson:"spdx,omitempty" json:"spdx,omitempty"` //normalized identifier of the first recognised license
 }
 
 type Date struct {
-	Day   string `bson:"day,omitempty" json:"day,omitempty"`
-	Month string `bson:"month,omitempty" json:"month,omitempty"`
-	Year  string `bson:"year,omitempty" json:"year,omitempty"`
+	Type              string    `bson:"type,omitempty" json:"type,omitempty"` //pub-type or date-type
+	PublicationFormat string    `bson:"publicationFormat,omitempty" json:"publicationFormat,omitempty"`
+	Day               string    `bson:"day,omitempty" json:"day,omitempty"`
+	Month             string    `bson:"month,omitempty" json:"month,omitempty"`
+	Year              string    `bson:"year,omitempty" json:"year,omitempty"`
+	Season            string    `bson:"season,omitempty" json:"season,omitempty"`
+	StringDate        string    `bson:"stringDate,omitempty" json:"stringDate,omitempty"`
+	Iso8601           string    `bson:"iso8601,omitempty" json:"iso8601,omitempty"`
+	Time              time.Time `bson:"time,omitempty" json:"time,omitempty"` //normalized, for sorting and range queries
+	Precision         string    `bson:"precision,omitempty" json:"precision,omitempty"`
 }
 
 type Node struct {
@@ -107,11 +156,15 @@ type Node struct {
 	Body      string            `bson:"body,omitempty" json:"body,omitempty"`         //only for text types
 	Children  []Node            `bson:"children,omitempty" json:"children,omitempty"` //only for tag types
 	Sentences []Sentence        `bson:"sentences,omitempty" json:"sentences,omitempty"`
+	MathML    string            `bson:"mathml,omitempty" json:"mathml,omitempty"` //only for mml:math tags
+	Tex       string            `bson:"tex,omitempty" json:"tex,omitempty"`       //only for tex-math tags
 }
 
 type Sentence struct {
-	Start int `bson:"start" json:"start"`
-	End   int `bson:"end" json:"end"`
+	Id    string `bson:"id,omitempty" json:"id,omitempty"` //stable across re-imports, see AssignSentenceIds
+	Start int    `bson:"start" json:"start"`               //UTF-16 code units into the block's text, see Utf16Offset
+	End   int    `bson:"end" json:"end"`
+	Words int    `bson:"words,omitempty" json:"words,omitempty"` //see Tokenize
 }
 
 type Meta struct {
@@ -156,158 +209,26 @@ func (this *Article) DecodeJSON(reader io.ReadCloser) error {
 	return decoder.Decode(&this)
 }
 
-func ArticleParseNodes(nodes []Node, depth int, sentences []Sentence, pmc string) (output string) {
-
-	/*
-		Type      string            `bson:"type,omitempty" json:"type,omitempty"`         //"text" or "tag"
-		Tag       string            `bson:"tag,omitempty" json:"tag,omitempty"`           //only for tag types
-		Props     map[string]string `bson:"props,omitempty" json:"props,omitempty"`       //only for tag types
-		Body      string            `bson:"body,omitempty" json:"body,omitempty"`         //only for text types
-		Children  []Node            `bson:"children,omitempty" json:"children,omitempty"` //only for tag types
-		Sentences []Sentence
-	*/
-
-	depth++
-	sentenceInd := 0
-	for _, node := range nodes {
-		//open node
-		if node.Type == "text" {
-			//parse sentences
-			body := node.Body
-
-			//log.Println("ind:", sentenceInd)
-
-			//check if sentence is started or ended
-			bodyEnd := len(body) + sentenceInd
-			for i := len(sentences) - 1; i >= 0; i-- {
-				sent := sentences[i]
-
-				if sent.End <= bodyEnd && sent.End >= sentenceInd {
-					end := sent.End - sentenceInd
-
-					if end != len(body) {
-						body = body[0:end] + "</div>" + body[end:len(body)]
-					} else {
-						body = body[0:end] + "</div>"
-					}
-
-				}
-				if sent.Start <= bodyEnd && sent.Start >= sentenceInd {
-					start := sent.Start - sentenceInd
-					//log.Println("sent start:", sent, start, len(body), body)
-					//log.Println("sent start:", start)
-
-					if start != 0 {
-						body = body[0:start] + "<div class=\"ae-sentence\">" + body[start:len(body)]
-					} else {
-						body = "<div class=\"ae-sentence\">" + body[start:len(body)]
-					}
-
-				}
-			}
-
-			sentenceInd += len(node.Body)
-
-			//loop backwards through
-			//body = body[]+"<div class=\"ae-sentence\">"
-
-			if len(body) > 0 {
-				if body[0:1] == ")" {
-					body = body[1:len(body)]
-				}
-				if body[len(body)-1:len(body)] == "(" {
-					body = body[:len(body)-1]
-				}
-			}
+func ArticleGetByDoi(doi string) (article Article, err error) {
+	query := bson.M{
+		"doiKey": DoiKey(doi),
+	}
 
-			output += body
-		}
-		if node.Type == "tag" {
-			tag := node.Tag
-			props := map[string]string{}
-			if tag == "title" {
-				if depth < 3 {
-					tag = "h2"
-				} else if depth < 4 {
-					//log.Println("depth:", depth)
-					tag = "h3"
-				} else {
-					tag = "h4"
-				}
-			}
-			if tag == "sec" {
-				tag = "div"
-				props["class"] = "ae-paragraph"
-			}
-			if tag == "p" {
-				tag = "div"
-				props["class"] = "ae-paragraph"
-			}
-			if tag == "xref" {
-				tag = "a"
-				props["href"] = "javascript:"
-				props["data-addition-id"] = "citation"
-				props["class"] = "article-additional"
-			}
-			if tag == "ext-link" {
-				tag = "a"
-				props["href"] = "javascript:"
-				props["data-addition-id"] = "citation"
-				props["class"] = "long-word"
-			}
-			if tag == "inline-formula" {
-				//log.Println("formula:", node.Props)
-				tag = "span"
-			}
-			if tag == "disp-formula" {
-				//log.Println("formula:", node.Props)
-				tag = "div"
-				props["class"] = "mobile-small ae-paragraph"
-			}
-			if tag == "inline-graphic" {
-				//log.Println("graphic:", node.Props)
-				tag = "img"
-				props["src"] = "http://www.ncbi.nlm.nih.gov/pmc/articles/PMC" + pmc + "/bin/" + node.Props["xlink:href"]
-				//http://www.ncbi.nlm.nih.gov/pmc/articles/PMC3592458/bin/gks981i3.jpg
-			}
-			if tag == "graphic" {
-				//log.Println("graphic:", node.Props)
-				tag = "img"
-				props["src"] = "http://www.ncbi.nlm.nih.gov/pmc/articles/PMC" + pmc + "/bin/" + node.Props["xlink:href"] + ".jpg"
-				//http://www.ncbi.nlm.nih.gov/pmc/articles/PMC3592458/bin/gks981i3.jpg
-			}
-			if tag == "fig" {
-				//is a figure - need to import figure into side panel
-				tag = "fig"
-				props["style"] = "display:none;"
-			}
+	log.Println("get by doi:", doi)
 
-			output += "<" + tag
-			//write props
-			for prop, val := range props {
-				output += " " + prop + "=\"" + val + "\""
-			}
-			output += ">"
-			if node.Tag == "xref" {
-				output += "["
-			}
-			output += ArticleParseNodes(node.Children, depth, node.Sentences, pmc)
-			if node.Tag == "xref" {
-				output += "]"
-			}
-			//close node
-			output += "</" + tag + ">"
-		}
+	err = db.GetCol("articles").Find(query).One(&article)
+	if err != nil {
+		return
 	}
 	return
 }
 
-func ArticleGetByDoi(doi string) (article Article, err error) {
+func ArticleGetByPmc(pmc string) (article Article, err error) {
 	query := bson.M{
-		"doi": doi,
+		"pmc": pmc,
 	}
 
-	log.Println("get by doi:", doi)
+	log.Println("get by pmc:", pmc)
 
 	err = db.GetCol("articles").Find(query).One(&article)
 	if err != nil {
@@ -316,12 +237,12 @@ func ArticleGetByDoi(doi string) (article Article, err error) {
 	return
 }
 
-func ArticleGetByPmc(pmc string) (article Article, err error) {
+func ArticleGetByPmid(pmid string) (article Article, err error) {
 	query := bson.M{
-		"pmc": pmc,
+		"pmid": pmid,
 	}
 
-	log.Println("get by pmc:", pmc)
+	log.Println("get by pmid:", pmid)
 
 	err = db.GetCol("articles").Find(query).One(&article)
 	if err != nil {
@@ -347,6 +268,18 @@ func ArticleGetById(id string) (article Article, err error) {
 	return
 }
 
+func ArticleListByPubDate(from time.Time, to time.Time) (articles []Article, err error) {
+	query := bson.M{
+		"pubDate": bson.M{
+			"$gte": from,
+			"$lt":  to,
+		},
+	}
+
+	err = db.GetCol("articles").Find(query).Sort("-pubDate").All(&articles)
+	return
+}
+
 func ArticleImportByDoi(doi string) (article Article, err error) {
 	//get doi
 	pmc, err := ArticlePmcByDoi(doi)
@@ -369,10 +302,10 @@ func ArticleImportByDoi(doi string) (article Article, err error) {
 
 func ArticlePmcByDoi(doi string) (pmc string, err error) {
 	resp, err := http.Get("http://eutils.ncbi.nlm.nih.gov/entrez/eutils/esearch.fcgi?retmode=json&db=pmc&term=" + doi)
-	defer resp.Body.Close()
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -399,43 +332,71 @@ func ArticlePmcByDoi(doi string) (pmc string, err error) {
 }
 
 func ArticleImportByPmc(pmc string) (article Article, err error) {
-	resp, err := http.Get("http://eutils.ncbi.nlm.nih.gov/entrez/eutils/efetch.fcgi?db=pmc&name=pubchase&retmode=xml&id=" + pmc)
-	defer resp.Body.Close()
+	article, err = ArticleFetchByPmc(pmc)
 	if err != nil {
 		return
 	}
 
-	//replace tags
+	log.Println("article imported")
+
+	if article.Pmc == "" {
+		return
+	}
+
+	article.Id = bson.NewObjectId()
+	err = article.LinkRelated()
+	if err != nil {
+		return
+	}
+
+	err = db.GetCol("articles").Insert(article)
+
+	return
+}
 
-	doc, err := html.Parse(resp.Body)
+func ArticleFetchByPmc(pmc string) (article Article, err error) {
+	resp, err := http.Get("http://eutils.ncbi.nlm.nih.gov/entrez/eutils/efetch.fcgi?db=pmc&name=pubchase&retmode=xml&id=" + pmc)
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
 
-	err = article.Parse(doc)
+	//replace tags
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return
 	}
 
-	log.Println("article imported")
+	doc, err := html.Parse(bytes.NewReader(ArticlePrepareXml(body)))
+	if err != nil {
+		return
+	}
 
-	if article.Pmc == "" {
+	article, report, err := ArticleParse(doc, false)
+	if err != nil {
 		return
 	}
+	for _, d := range report.Diagnostics {
+		log.Println("import pmc", pmc, d.Error())
+	}
 
-	err = db.GetCol("articles").Insert(article)
+	article.CheckEmbargo(time.Now())
 
 	return
 }
 
 func (this *Article) Parse(n *html.Node) (err error) {
 	if n.Data == "article" {
+		this.parsed = true
 		return this.ParseArticle(n)
 		//log.Println(n.Data, n.Attr, level)
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.Parse(c)
+		err = this.Parse(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -449,6 +410,9 @@ func (this *Article) ParseArticle(n *html.Node) (err error) {
 	if n.Data == "article-meta" {
 		return this.ParseMeta(n)
 	}
+	if n.Data == "sub-article" || n.Data == "response" {
+		return this.ParseSubArticle(n)
+	}
 	//log.Println("data:", n.Data, n.Namespace, n.DataAtom, n.Type, n.Attr)
 	if n.Data == "sec" {
 
@@ -476,48 +440,94 @@ func (this *Article) ParseArticle(n *html.Node) (err error) {
 		if a.Key == "article-type" {
 			//log.Println("article type:", a.Val)
 			this.Type = a.Val
-			break
+		} else if a.Key == "xml:lang" && n.Data == "article" {
+			this.Lang = a.Val
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		err = this.ParseArticle(c)
+		if err != nil {
+			return
 		}
 	}
 
+	return
+}
+
+func (this *Article) ParseSubArticle(n *html.Node) (err error) {
+
+	sub := Article{report: this.report}
+	for _, a := range n.Attr {
+		if a.Key == "article-type" || a.Key == "response-type" {
+			sub.Type = a.Val
+		} else if a.Key == "id" {
+			sub.XmlId = a.Val
+		} else if a.Key == "xml:lang" {
+			sub.Lang = a.Val
+		}
+	}
+
+	//the html parser drops the <body> tag, so body content sits directly under the sub-article
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseArticle(c)
+		if c.Data == "front-stub" || c.Data == "front" {
+			err = sub.ParseMeta(c)
+		} else if c.Data == "back" {
+			err = sub.ParseBack(c)
+		} else if c.Data == "sub-article" || c.Data == "response" {
+			err = sub.ParseSubArticle(c)
+		} else if c.Type == html.ElementNode || c.Type == html.TextNode {
+			var node Node
+			node, err = sub.ParseNode(c)
+			if node.Type == "tag" || strings.TrimSpace(node.Body) != "" {
+				sub.Body = append(sub.Body, node)
+			}
+		}
+		if err != nil {
+			return
+		}
 	}
 
+	sub.report = nil
+	this.SubArticles = append(this.SubArticles, sub)
 	return
 }
 
+func (this *Article) RenderSubArticles() (outputs []string, access Access) {
+	return DefaultRenderer.RenderSubArticles(this)
+}
+
 func (this *Article) ParseJournal(n *html.Node) (err error) {
 
 	if n.Data == "journal-id" {
 		for _, a := range n.Attr {
 			if a.Key == "journal-id-type" {
 				if a.Val == "nlm-ta" {
-					this.Journal.NlmTa = ParseInner(n)
+					this.Journal.NlmTa = ParseText(n)
 				}
 				if a.Val == "iso-abbrev" {
-					this.Journal.IsoAbbrev = ParseInner(n)
+					this.Journal.IsoAbbrev = ParseText(n)
 				}
 				if a.Val == "publisher-id" {
-					this.Journal.PublisherId = ParseInner(n)
+					this.Journal.PublisherId = ParseText(n)
 				}
 				if a.Val == "hwp" {
-					this.Journal.Hwp = ParseInner(n)
+					this.Journal.Hwp = ParseText(n)
 				}
 				break
 			}
 		}
 	} else if n.Data == "journal-title" {
-		title := ParseInner(n)
+		title := ParseText(n)
 		this.Journal.Titles = append(this.Journal.Titles, title)
 	} else if n.Data == "issn" {
 		for _, a := range n.Attr {
 			if a.Key == "pub-type" {
 				if a.Val == "ppub" {
-					this.Journal.Ppub = ParseInner(n)
+					this.Journal.Ppub = ParseText(n)
 				}
 				if a.Val == "epub" {
-					this.Journal.Epub = ParseInner(n)
+					this.Journal.Epub = ParseText(n)
 				}
 				break
 			}
@@ -525,7 +535,10 @@ func (this *Article) ParseJournal(n *html.Node) (err error) {
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseJournal(c)
+		err = this.ParseJournal(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -539,16 +552,17 @@ func (this *Article) ParseMeta(n *html.Node) (err error) {
 		for _, a := range n.Attr {
 			if a.Key == "pub-id-type" {
 				if a.Val == "pmid" {
-					this.Pmid = ParseInner(n)
+					this.Pmid = ParseText(n)
 				}
 				if a.Val == "pmc" {
-					this.Pmc = ParseInner(n)
+					this.Pmc = ParseText(n)
 				}
 				if a.Val == "doi" {
-					this.Doi = ParseInner(n)
+					this.Doi = NormalizeDoi(ParseText(n))
+					this.DoiKey = DoiKey(this.Doi)
 				}
 				if a.Val == "publisher-id" {
-					this.PublisherId = ParseInner(n)
+					this.PublisherId = ParseText(n)
 				}
 				break
 			}
@@ -566,32 +580,32 @@ func (this *Article) ParseMeta(n *html.Node) (err error) {
 	} else if n.Data == "pub-date" {
 		return this.ParsePubDate(n)
 	} else if n.Data == "volume" {
-		this.Volume = ParseInner(n)
+		this.Volume = ParseText(n)
 	} else if n.Data == "issue" {
-		this.Issue = ParseInner(n)
+		this.Issue = ParseText(n)
 	} else if n.Data == "fpage" {
-		this.Fpage = ParseInner(n)
+		this.Fpage = ParseText(n)
 	} else if n.Data == "lpage" {
-		this.Lpage = ParseInner(n)
+		this.Lpage = ParseText(n)
 	} else if n.Data == "history" {
 		return this.ParseHistory(n)
 	} else if n.Data == "permissions" {
 		return this.ParsePermissions(n)
-	} else if n.Data == "abstract" {
-		this.Abstract, err = this.ParseChildren(n)
-	} else if n.Data == "page-count" {
-		for _, a := range n.Attr {
-			if a.Key == "count" {
-				this.PageCount, err = strconv.Atoi(a.Val)
-				break
-			}
-		}
+	} else if n.Data == "abstract" || n.Data == "trans-abstract" {
+		return this.ParseAbstract(n)
+	} else if n.Data == "counts" || n.Data == "page-count" {
+		return this.ParseCounts(n)
 	} else if n.Data == "custom-meta-group" {
 		return this.ParseCustomMeta(n)
+	} else if n.Data == "related-article" {
+		return this.ParseRelatedArticle(n)
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseMeta(c)
+		err = this.ParseMeta(c)
+		if err != nil {
+			return
+		}
 	}
 
 	//log.Println("META PARSED!!")
@@ -599,16 +613,88 @@ func (this *Article) ParseMeta(n *html.Node) (err error) {
 	return
 }
 
+func (this *Article) ParseAbstract(n *html.Node) (err error) {
+
+	abstract := Abstract{
+		Translated: n.Data == "trans-abstract",
+	}
+	for _, a := range n.Attr {
+		if a.Key == "abstract-type" {
+			abstract.Type = a.Val
+		}
+		if a.Key == "xml:lang" {
+			abstract.Lang = a.Val
+		}
+	}
+
+	abstract.Children, err = this.ParseChildren(n)
+	if err != nil {
+		return
+	}
+
+	this.Abstracts = append(this.Abstracts, abstract)
+	this.Abstract = this.PrimaryAbstract().Children
+
+	return
+}
+
+func (this *Article) PrimaryAbstract() (abstract Abstract) {
+	//prefer the untyped original-language abstract, then any original-language one
+	for _, a := range this.Abstracts {
+		if a.Type == "" && !a.Translated {
+			return a
+		}
+	}
+	for _, a := range this.Abstracts {
+		if !a.Translated {
+			return a
+		}
+	}
+	if len(this.Abstracts) > 0 {
+		abstract = this.Abstracts[0]
+	}
+	return
+}
+
+func (this *Article) AbstractByType(abstractType string, lang string) (abstract Abstract, found bool) {
+	//empty lang matches any language
+	for _, a := range this.Abstracts {
+		if a.Type == abstractType && (lang == "" || a.Lang == lang) {
+			return a, true
+		}
+	}
+	return
+}
+
+func (this *Article) RenderAbstract(abstractType string, lang string) (output string, access Access) {
+	return DefaultRenderer.RenderAbstract(this, abstractType, lang)
+}
+
 func (this *Article) ParseBack(n *html.Node) (err error) {
 
 	if n.Data == "ack" {
 		this.Ack, err = this.ParseChildren(n)
-	} else if n.Data == "ref-list" {
-		err = this.ParseRefs(n)
+		return
+	}
+	if n.Data == "ref-list" {
+		return this.ParseRefs(n)
+	}
+	if BackMatterElements[n.Data] && n.Parent != nil && n.Parent.Data == "back" {
+		//statements such as data availability or competing interests usually live here
+		var node Node
+		node, err = this.ParseNode(n)
+		if err != nil {
+			return
+		}
+		this.Back = append(this.Back, node)
+		return
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseBack(c)
+		err = this.ParseBack(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -619,7 +705,7 @@ func (this *Article) ParseCategories(n *html.Node) (err error) {
 	if n.Data == "subj-group" {
 		for _, a := range n.Attr {
 			if a.Key == "subj-group-type" {
-				subject := ParseChildInner(n, "subject")
+				subject := ParseChildText(n, "subject")
 				//log.Println("subject:", subject)
 				cat := Category{
 					Group:   a.Val,
@@ -632,7 +718,10 @@ func (this *Article) ParseCategories(n *html.Node) (err error) {
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseCategories(c)
+		err = this.ParseCategories(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -641,11 +730,98 @@ func (this *Article) ParseCategories(n *html.Node) (err error) {
 func (this *Article) ParseTitles(n *html.Node) (err error) {
 
 	if n.Data == "article-title" {
-		this.Titles = append(this.Titles, ParseInner(n))
+		title := Title{}
+		title.Children, err = this.ParseChildren(n)
+		if err != nil {
+			return
+		}
+		title.Text = NodesText(title.Children)
+		if this.Title.Text == "" {
+			this.Title.Text = title.Text
+			this.Title.Children = title.Children
+		}
+		this.Titles = append(this.Titles, title.Text)
+		return
+	} else if n.Data == "subtitle" {
+		this.Title.SubtitleChildren, err = this.ParseChildren(n)
+		this.Title.Subtitle = NodesText(this.Title.SubtitleChildren)
+		return
+	} else if n.Data == "alt-title" {
+		title := Title{}
+		for _, a := range n.Attr {
+			if a.Key == "alt-title-type" {
+				title.Type = a.Val
+			}
+			if a.Key == "xml:lang" {
+				title.Lang = a.Val
+			}
+		}
+		title.Children, err = this.ParseChildren(n)
+		if err != nil {
+			return
+		}
+		title.Text = NodesText(title.Children)
+		this.AltTitles = append(this.AltTitles, title)
+		return
+	} else if n.Data == "trans-title-group" {
+		title := Title{}
+		for _, a := range n.Attr {
+			if a.Key == "xml:lang" {
+				title.Lang = a.Val
+			}
+		}
+		err = this.ParseTransTitle(n, &title)
+		if err != nil {
+			return
+		}
+		this.TransTitles = append(this.TransTitles, title)
+		return
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseTitles(c)
+		err = this.ParseTitles(c)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this *Article) ParseTransTitle(n *html.Node, title *Title) (err error) {
+
+	if n.Data == "trans-title" {
+		title.Children, err = this.ParseChildren(n)
+		title.Text = NodesText(title.Children)
+		return
+	} else if n.Data == "trans-subtitle" {
+		title.SubtitleChildren, err = this.ParseChildren(n)
+		title.Subtitle = NodesText(title.SubtitleChildren)
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		err = this.ParseTransTitle(c, title)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this *Article) AltTitle(altTitleType string) (title Title, found bool) {
+	for _, t := range this.AltTitles {
+		if t.Type == altTitleType {
+			return t, true
+		}
+	}
+	return
+}
+
+func (this *Article) TransTitle(lang string) (title Title, found bool) {
+	for _, t := range this.TransTitles {
+		if t.Lang == lang {
+			return t, true
+		}
 	}
 	return
 }
@@ -657,7 +833,10 @@ func (this *Article) ParseContributors(n *html.Node) (err error) {
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseContributors(c)
+		err = this.ParseContributors(c)
+		if err != nil {
+			return
+		}
 	}
 	return
 }
@@ -667,8 +846,8 @@ func (this *Article) ParseContributor(n *html.Node) (err error) {
 		if a.Key == "contrib-type" {
 			contrib := Contributor{}
 			contrib.Type = a.Val
-			contrib.Surname = ParseChildInner(n, "surname")
-			contrib.GivenNames = ParseChildInner(n, "given-names")
+			contrib.Surname = ParseChildText(n, "surname")
+			contrib.GivenNames = ParseChildText(n, "given-names")
 			this.Contributors = append(this.Contributors, contrib)
 			break
 		}
@@ -708,7 +887,10 @@ func (this *Article) ParseAuthorNotes(n *html.Node) (err error) {
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseAuthorNotes(c)
+		err = this.ParseAuthorNotes(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -716,19 +898,33 @@ func (this *Article) ParseAuthorNotes(n *html.Node) (err error) {
 
 func (this *Article) ParsePubDate(n *html.Node) (err error) {
 
-	for _, a := range n.Attr {
-		if a.Key == "pub-type" {
-			date := Date{}
-			date.Day = ParseChildInner(n, "day")
-			date.Month = ParseChildInner(n, "month")
-			date.Year = ParseChildInner(n, "year")
-			if a.Val == "ppub" {
-				this.Ppub = date
-			} else if a.Val == "epub" {
-				this.Epub = date
-			} else if a.Val == "pmc-release" {
-				this.PmcRelease = date
-			}
+	date := ParseDate(n)
+	if date.Type == "" && date.PublicationFormat == "" {
+		this.warn(n, "pub-date without pub-type or date-type")
+		return
+	}
+	this.checkDate(n, date)
+	this.PubDates = append(this.PubDates, date)
+
+	//older pub-type values, or the newer date-type/publication-format pairs
+	if date.Type == "ppub" || (date.Type == "pub" && date.PublicationFormat == "print") {
+		this.Ppub = date
+	} else if date.Type == "epub" || (date.Type == "pub" && date.PublicationFormat == "electronic") {
+		this.Epub = date
+	} else if date.Type == "epub-ppub" {
+		this.Ppub = date
+		this.Epub = date
+	} else if date.Type == "pmc-release" {
+		this.PmcRelease = date
+	} else if date.Type == "collection" {
+		this.Collection = date
+	}
+
+	this.PubDate = time.Time{}
+	for _, d := range []Date{this.Epub, this.Ppub, this.Collection} {
+		if !d.Time.IsZero() {
+			this.PubDate = d.Time
+			break
 		}
 	}
 
@@ -738,46 +934,59 @@ func (this *Article) ParsePubDate(n *html.Node) (err error) {
 func (this *Article) ParseHistory(n *html.Node) (err error) {
 
 	if n.Data == "date" {
-		date := Date{}
-		date.Day = ParseChildInner(n, "day")
-		date.Month = ParseChildInner(n, "month")
-		date.Year = ParseChildInner(n, "year")
-		for _, a := range n.Attr {
-			if a.Key == "date-type" {
-				if a.Val == "received" {
-					this.History.Received = date
-				} else if a.Val == "rev-recd" {
-					this.History.RevRecd = date
-				} else if a.Val == "accepted" {
-					this.History.Accepted = date
-				}
-			}
+		date := ParseDate(n)
+		this.checkDate(n, date)
+		if date.Type == "received" {
+			this.History.Received = date
+		} else if date.Type == "rev-recd" {
+			this.History.RevRecd = date
+		} else if date.Type == "accepted" {
+			this.History.Accepted = date
 		}
+		this.History.Dates = append(this.History.Dates, date)
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseHistory(c)
+		err = this.ParseHistory(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
 }
 
+func (this *Article) checkDate(n *html.Node, date Date) {
+	if date.Time.IsZero() {
+		this.warn(n, "unparseable "+date.Type+" date")
+	} else if problem := date.Problem(); problem != "" {
+		this.warn(n, problem)
+	}
+}
+
 func (this *Article) ParsePermissions(n *html.Node) (err error) {
 
 	if n.Data == "copyright-statement" {
-		this.Permissions.CopyrightStatement = ParseInner(n)
+		this.Permissions.CopyrightStatement = ParseText(n)
 	} else if n.Data == "copyright-year" {
-		this.Permissions.CopyrightYear = ParseInner(n)
+		this.Permissions.CopyrightYear = ParseText(n)
 	} else if n.Data == "license" {
-		for _, a := range n.Attr {
-			if a.Key == "license-type" {
-				this.Permissions.Licenses = append(this.Permissions.Licenses, a.Val)
-			}
+		license := this.ParseLicense(n)
+		if license.Type != "" {
+			this.Permissions.Licenses = append(this.Permissions.Licenses, license.Type)
+		}
+		this.Permissions.LicenseDetails = append(this.Permissions.LicenseDetails, license)
+		if this.Permissions.Spdx == "" {
+			this.Permissions.Spdx = license.Spdx
 		}
+		return
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParsePermissions(c)
+		err = this.ParsePermissions(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -787,14 +996,17 @@ func (this *Article) ParseCustomMeta(n *html.Node) (err error) {
 
 	if n.Data == "custom-meta" {
 		meta := Meta{
-			Name:  ParseChildInner(n, "meta-name"),
-			Value: ParseChildInner(n, "meta-value"),
+			Name:  ParseChildText(n, "meta-name"),
+			Value: ParseChildText(n, "meta-value"),
 		}
 		this.Metas = append(this.Metas, meta)
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseCustomMeta(c)
+		err = this.ParseCustomMeta(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -802,8 +1014,8 @@ func (this *Article) ParseCustomMeta(n *html.Node) (err error) {
 
 func (this *Article) ParseRefs(n *html.Node) (err error) {
 
-	if n.Data == "title" {
-		this.Refs.Title = ParseInner(n)
+	if JatsName(n) == "title" {
+		this.Refs.Title = ParseText(n)
 	}
 	if n.Data == "ref" {
 		ref := Ref{}
@@ -812,15 +1024,27 @@ func (this *Article) ParseRefs(n *html.Node) (err error) {
 				ref.Id = a.Val
 			}
 		}
-		ref.Label = ParseChildInner(n, "label")
-		err = ref.ParseGroups(n)
-		err = ref.ParseElement(n)
+		ref.Label = ParseChildText(n, "label")
+		err = this.check(n, ref.ParseGroups(n))
+		if err != nil {
+			return
+		}
+		err = this.check(n, ref.ParseElement(n))
+		if err != nil {
+			return
+		}
+		if ref.Title == "" && ref.Source == "" {
+			this.warn(n, "reference "+ref.Id+" has no element-citation title or source")
+		}
 
 		this.Refs.List = append(this.Refs.List, ref)
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseRefs(c)
+		err = this.ParseRefs(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -843,7 +1067,10 @@ func (this *Ref) ParseGroups(n *html.Node) (err error) {
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		this.ParseGroups(c)
+		err = this.ParseGroups(c)
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -854,7 +1081,10 @@ func (this *Group) ParseNames(n *html.Node) (err error) {
 	if n.Data == "name" {
 		name := map[string]string{}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			inner := ParseInner(c)
+			if c.Type != html.ElementNode {
+				continue
+			}
+			inner := ParseText(c)
 			if inner != "" {
 				name[c.Data] = inner
 			}
@@ -878,13 +1108,13 @@ func (this *Ref) ParseElement(n *html.Node) (err error) {
 
 	if n.Data == "element-citation" {
 		//log.Println("element:", n.Data)
-		this.Title = ParseChildInner(n, "article-title")
-		this.Source = ParseChildInner(n, "source")
-		this.Year = ParseChildInner(n, "year")
-		this.Volume = ParseChildInner(n, "volume")
-		this.Fpage = ParseChildInner(n, "fpage")
-		this.Lpage = ParseChildInner(n, "lpage")
-		this.Pmid = ParseChildInner(n, "pub-id")
+		this.Title = ParseChildText(n, "article-title")
+		this.Source = ParseChildText(n, "source")
+		this.Year = ParseChildText(n, "year")
+		this.Volume = ParseChildText(n, "volume")
+		this.Fpage = ParseChildText(n, "fpage")
+		this.Lpage = ParseChildText(n, "lpage")
+		this.Pmid = ParseChildText(n, "pub-id")
 		return
 	}
 
@@ -895,13 +1125,13 @@ func (this *Ref) ParseElement(n *html.Node) (err error) {
 	return
 }
 
-func ParseChildInner(n *html.Node, child string) (val string) {
+func ParseChildText(n *html.Node, child string) (val string) {
 	if n.Data == child {
-		val = ParseInner(n)
+		val = ParseText(n)
 		return
 	}
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		v := ParseChildInner(c, child)
+		v := ParseChildText(c, child)
 		if v != "" {
 			val = v
 		}
@@ -909,44 +1139,67 @@ func ParseChildInner(n *html.Node, child string) (val string) {
 	return
 }
 
+// Deprecated: use ParseChildText, which this now is.
+func ParseChildInner(n *html.Node, child string) (val string) {
+	//kept for callers outside this package
+	return ParseChildText(n, child)
+}
+
+// Deprecated: use ParseText, which this now is; it returns all the text, not only the first text node.
 func ParseInner(n *html.Node) (inner string) {
-	c := n.FirstChild
-	//log.Println("parse inner", n.Data, n.Attr, c.Data)
-	if c == nil {
-		return ""
+	//kept for callers outside this package
+	return ParseText(n)
+}
+
+func ParseText(n *html.Node) (text string) {
+	//all descendant text, so inline markup doesn't truncate the value
+	return NormalizeSpace(parseText(n))
+}
+
+func parseText(n *html.Node) (text string) {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text += parseText(c)
+	}
+	return
+}
+
+func NodesText(nodes []Node) (text string) {
+	//plain text of a node tree with whitespace collapsed
+	return NormalizeSpace(nodesText(nodes))
+}
+
+func nodesText(nodes []Node) (text string) {
+	for _, node := range nodes {
+		if node.Type == "text" {
+			text += node.Body
+		} else {
+			text += nodesText(node.Children)
+		}
 	}
-	inner = c.Data
 	return
 }
 
+func NormalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 func (this *Article) ParseChildren(n *html.Node) (children []Node, err error) {
 
 	//read in each child node
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		child := Node{}
-		if c.Type == html.TextNode {
-			child.Type = "text"
-			child.Body = c.Data
-		} else {
-			child.Type = "tag"
-			child.Tag = c.Data
-			child.Props = map[string]string{}
-			for _, a := range c.Attr {
-				//log.Println("key:", a.Key, child.Props)
-				child.Props[a.Key] = a.Val
-			}
-			child.Children, err = this.ParseChildren(c)
-			if err != nil {
-				return
-			}
-			err = child.GetSentences()
-			if err != nil {
-				return
-			}
-
+		var child Node
+		child, err = this.ParseNode(c)
+		if err != nil {
+			return
 		}
 		children = append(children, child)
-		this.ParseContributors(c)
+		err = this.ParseContributors(c)
+		if err != nil {
+			return
+		}
 	}
 
 	/*
@@ -961,36 +1214,81 @@ func (this *Article) ParseChildren(n *html.Node) (children []Node, err error) {
 	return
 }
 
-func (this *Node) GetSentences() (err error) {
-	//loop through children and get sentences start and end
+func (this *Article) ParseNode(c *html.Node) (child Node, err error) {
+	if c.Type == html.TextNode {
+		child.Type = "text"
+		child.Body = c.Data
+		return
+	}
 
-	body := ""
-	for _, child := range this.Children {
-		if child.Type == "text" {
-			body += child.Body
-		}
+	child.Type = "tag"
+	child.Tag = JatsName(c)
+	child.Props = map[string]string{}
+	for _, a := range c.Attr {
+		//log.Println("key:", a.Key, child.Props)
+		child.Props[a.Key] = a.Val
+	}
+	if IsMathTag(c.Data) {
+		//kept serialized, the markup isn't article text
+		child.MathML = ParseMathML(c)
+		return
+	}
+	if c.Data == "tex-math" {
+		child.Tex = parseText(c)
+		return
+	}
+	child.Children, err = this.ParseChildren(c)
+	if err != nil {
+		return
+	}
+	if !IsInlineTag(c.Data) {
+		err = this.check(c, child.SegmentSentences(SegmenterForLang(NodeLang(c))))
 	}
+	return
+}
+
+func (this *Node) GetSentences() (err error) {
+	//sentences over the text of this block, including text inside inline children
+	return this.SegmentSentences(SegmenterForLang(this.Props["xml:lang"]))
+}
+
+func (this *Node) SegmentSentences(segmenter Segmenter) (err error) {
+	body := inlineText(this.Children)
 
 	//split based on sentences
-	i := 0
-	curI := 0
-	for i != -1 {
-		i = strings.Index(body, ". ")
-		sent := Sentence{}
-		if i == -1 { //none found
-			sent.Start = curI
-			sent.End = curI + len(body)
-		} else {
-			sent.Start = curI
-			sent.End = curI + i + 1
+	this.Sentences = nil
+	for _, sent := range segmenter.Segment(body) {
+		if strings.TrimSpace(body[sent.Start:sent.End]) != "" {
+			this.Sentences = append(this.Sentences, Sentence{
+				Start: Utf16Offset(body, sent.Start),
+				End:   Utf16Offset(body, sent.End),
+				Words: WordCount(body[sent.Start:sent.End]),
+			})
 		}
-		this.Sentences = append(this.Sentences, sent)
-		if i == -1 {
-			break
+	}
+
+	return
+}
+
+func NodeLang(n *html.Node) string {
+	//xml:lang of n or its nearest ancestor that has one, usually the article
+	for ; n != nil; n = n.Parent {
+		for _, a := range n.Attr {
+			if a.Key == "xml:lang" {
+				return a.Val
+			}
 		}
-		body = body[i+1:]
-		curI += i + 1
 	}
+	return ""
+}
 
+func inlineText(nodes []Node) (text string) {
+	for _, node := range nodes {
+		if node.Type == "text" {
+			text += node.Body
+		} else if IsInlineTag(node.Tag) {
+			text += inlineText(node.Children)
+		}
+	}
 	return
 }
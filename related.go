@@ -0,0 +1,270 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"nofe/db"
+)
+
+const (
+	ArticleStatusRetracted = "retracted"
+	ArticleStatusConcern   = "expression-of-concern"
+	ArticleStatusCorrected = "corrected"
+)
+
+type RelatedArticle struct {
+	Type      string        `bson:"type,omitempty" json:"type,omitempty"` //related-article-type
+	Doi       string        `bson:"doi,omitempty" json:"doi,omitempty"`
+	DoiKey    string        `bson:"doiKey,omitempty" json:"-"` //lowercased Doi, indexed for LinkNotices
+	Pmid      string        `bson:"pmid,omitempty" json:"pmid,omitempty"`
+	Pmc       string        `bson:"pmc,omitempty" json:"pmc,omitempty"`
+	Href      string        `bson:"href,omitempty" json:"href,omitempty"`
+	Text      string        `bson:"text,omitempty" json:"text,omitempty"`
+	ArticleId bson.ObjectId `bson:"articleId,omitempty" json:"articleId,omitempty"` //stored article it points to
+}
+
+// status this article gets from its own related-article links
+var relatedStatus = map[string]string{
+	"retraction-forward":    ArticleStatusRetracted,
+	"expression-of-concern": ArticleStatusConcern,
+	"concern-forward":       ArticleStatusConcern,
+	"correction-forward":    ArticleStatusCorrected,
+}
+
+// status a notice gives to the article it points to, and the link back
+var noticeStatus = map[string]string{
+	"retracted-article": ArticleStatusRetracted,
+	"object-of-concern": ArticleStatusConcern,
+	"corrected-article": ArticleStatusCorrected,
+}
+
+var noticeForward = map[string]string{
+	"retracted-article": "retraction-forward",
+	"object-of-concern": "concern-forward",
+	"corrected-article": "correction-forward",
+}
+
+// resolver links and the "doi:" scheme some publishers put in front of the doi
+var doiPrefix = regexp.MustCompile(`(?i)^(?:https?://(?:dx\.)?doi\.org/|doi:\s*)`)
+
+func NormalizeDoi(doi string) string {
+	//the bare doi, without resolver prefix, its case is kept but means nothing
+	return strings.TrimSpace(doiPrefix.ReplaceAllString(strings.TrimSpace(doi), ""))
+}
+
+func DoiKey(doi string) string {
+	//dois are case insensitive, Doi keeps the publisher's case and DoiKey is what lookups compare
+	return strings.ToLower(NormalizeDoi(doi))
+}
+
+func SameDoi(a string, b string) bool {
+	a, b = NormalizeDoi(a), NormalizeDoi(b)
+	return a != "" && strings.EqualFold(a, b)
+}
+
+func (this *Article) ParseRelatedArticle(n *html.Node) (err error) {
+
+	related := RelatedArticle{
+		Text: ParseText(n),
+	}
+	extLinkType := ""
+	for _, a := range n.Attr {
+		if a.Key == "related-article-type" {
+			related.Type = a.Val
+		} else if a.Key == "ext-link-type" {
+			extLinkType = a.Val
+		} else if a.Key == "xlink:href" {
+			related.Href = a.Val
+		}
+	}
+
+	if extLinkType == "doi" {
+		related.Doi = NormalizeDoi(related.Href)
+	} else if extLinkType == "pubmed" || extLinkType == "pmid" {
+		related.Pmid = related.Href
+	} else if extLinkType == "pmc" || extLinkType == "pmcid" {
+		related.Pmc = strings.TrimPrefix(strings.ToUpper(related.Href), "PMC")
+	} else if doi := NormalizeDoi(related.Href); strings.HasPrefix(doi, "10.") {
+		related.Doi = doi
+	}
+
+	related.DoiKey = DoiKey(related.Doi)
+
+	if related.Doi == "" && related.Pmid == "" && related.Pmc == "" {
+		this.warn(n, "related-article "+related.Type+" has no doi, pmid or pmc")
+	}
+
+	this.RelatedArticles = append(this.RelatedArticles, related)
+	this.SetStatus(relatedStatus[related.Type])
+
+	return
+}
+
+func (this *Article) SetStatus(status string) {
+	//a retraction outranks a concern, which outranks a correction
+	rank := map[string]int{
+		ArticleStatusCorrected: 1,
+		ArticleStatusConcern:   2,
+		ArticleStatusRetracted: 3,
+	}
+	if rank[status] > rank[this.Status] {
+		this.Status = status
+	}
+}
+
+func (this *Article) IsRetracted() bool {
+	return this.Status == ArticleStatusRetracted
+}
+
+func (this *Article) IsCorrected() bool {
+	return this.Status == ArticleStatusCorrected
+}
+
+func (this *RelatedArticle) Find() (article Article, err error) {
+	if this.Pmc != "" {
+		article, err = ArticleGetByPmc(this.Pmc)
+	} else if this.Doi != "" {
+		article, err = ArticleGetByDoi(this.Doi)
+	} else if this.Pmid != "" {
+		article, err = ArticleGetByPmid(this.Pmid)
+	}
+	return
+}
+
+func (this *Article) LinkRelated() (err error) {
+	//point related articles at the stored copies, and flag articles this one is a notice for
+	for i := range this.RelatedArticles {
+		related := &this.RelatedArticles[i]
+
+		target, err := related.Find()
+		if err != nil && err.Error() != "not found" {
+			return err
+		}
+		if target.Id == "" {
+			continue
+		}
+		related.ArticleId = target.Id
+
+		status := noticeStatus[related.Type]
+		if status == "" || this.Id == "" {
+			continue
+		}
+		target.SetStatus(status)
+		back := RelatedArticle{
+			Type:      noticeForward[related.Type],
+			Doi:       this.Doi,
+			DoiKey:    this.DoiKey,
+			Pmid:      this.Pmid,
+			Pmc:       this.Pmc,
+			ArticleId: this.Id,
+		}
+		err = db.GetCol("articles").UpdateId(target.Id, bson.M{
+			"$set":      bson.M{"status": target.Status},
+			"$addToSet": bson.M{"relatedArticles": back},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return this.LinkNotices()
+}
+
+func (this *Article) LinkNotices() (err error) {
+	//notices stored before this article was imported
+	or := []bson.M{}
+	if this.Pmc != "" {
+		or = append(or, bson.M{"relatedArticles.pmc": this.Pmc})
+	}
+	if this.Doi != "" {
+		or = append(or, bson.M{"relatedArticles.doiKey": DoiKey(this.Doi)})
+	}
+	if this.Pmid != "" {
+		or = append(or, bson.M{"relatedArticles.pmid": this.Pmid})
+	}
+	if len(or) == 0 {
+		return
+	}
+
+	var notices []Article
+	err = db.GetCol("articles").Find(bson.M{"$or": or}).All(&notices)
+	if err != nil {
+		return
+	}
+
+	for _, notice := range notices {
+		for _, related := range notice.RelatedArticles {
+			status := noticeStatus[related.Type]
+			if status == "" {
+				continue
+			}
+			if (related.Pmc == "" || related.Pmc != this.Pmc) &&
+				!SameDoi(related.Doi, this.Doi) &&
+				(related.Pmid == "" || related.Pmid != this.Pmid) {
+				continue
+			}
+			this.SetStatus(status)
+			this.RelatedArticles = append(this.RelatedArticles, RelatedArticle{
+				Type:      noticeForward[related.Type],
+				Doi:       notice.Doi,
+				DoiKey:    notice.DoiKey,
+				Pmid:      notice.Pmid,
+				Pmc:       notice.Pmc,
+				ArticleId: notice.Id,
+			})
+		}
+	}
+
+	return
+}
+
+// the fields ArticleGet* and LinkNotices look articles up by; LinkNotices runs on every import
+var articleIndexes = [][]string{
+	{"pmc"}, {"pmid"}, {"doiKey"},
+	{"relatedArticles.pmc"}, {"relatedArticles.pmid"}, {"relatedArticles.doiKey"},
+}
+
+func ArticleEnsureIndexes() (err error) {
+	for _, key := range articleIndexes {
+		err = db.GetCol("articles").EnsureIndex(mgo.Index{Key: key, Background: true})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func ArticleAssignDoiKeys() (err error) {
+	//articles stored before DoiKey existed, or with a resolver prefix on their doi
+	query := bson.M{"$or": []bson.M{
+		{"doi": bson.M{"$exists": true}, "doiKey": bson.M{"$exists": false}},
+		{"relatedArticles": bson.M{"$elemMatch": bson.M{"doi": bson.M{"$exists": true}, "doiKey": bson.M{"$exists": false}}}},
+	}}
+	article := Article{}
+	iter := db.GetCol("articles").Find(query).Select(bson.M{"doi": 1, "relatedArticles": 1}).Iter()
+	for iter.Next(&article) {
+		article.Doi = NormalizeDoi(article.Doi)
+		article.DoiKey = DoiKey(article.Doi)
+		for i := range article.RelatedArticles {
+			related := &article.RelatedArticles[i]
+			related.Doi = NormalizeDoi(related.Doi)
+			related.DoiKey = DoiKey(related.Doi)
+		}
+		set := bson.M{"relatedArticles": article.RelatedArticles}
+		if article.Doi != "" {
+			set["doi"], set["doiKey"] = article.Doi, article.DoiKey
+		}
+		err = db.GetCol("articles").UpdateId(article.Id, bson.M{"$set": set})
+		if err != nil {
+			iter.Close()
+			return
+		}
+		article = Article{}
+	}
+	return iter.Close()
+}
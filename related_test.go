@@ -0,0 +1,66 @@
+package models
+
+import "testing"
+
+func TestNormalizeDoi(t *testing.T) {
+	tests := []struct {
+		input string
+		doi   string
+	}{
+		{"10.1371/journal.pone.0001", "10.1371/journal.pone.0001"},
+		{" 10.1371/journal.pone.0001 ", "10.1371/journal.pone.0001"},
+		{"https://doi.org/10.1371/journal.pone.0001", "10.1371/journal.pone.0001"},
+		{"http://dx.doi.org/10.1371/journal.pone.0001", "10.1371/journal.pone.0001"},
+		{"HTTPS://DOI.ORG/10.1371/Journal.PONE.0001", "10.1371/Journal.PONE.0001"},
+		{"doi:10.1371/journal.pone.0001", "10.1371/journal.pone.0001"},
+		{"DOI: 10.1371/journal.pone.0001", "10.1371/journal.pone.0001"},
+	}
+	for _, test := range tests {
+		if doi := NormalizeDoi(test.input); doi != test.doi {
+			t.Errorf("%q: %q, want %q", test.input, doi, test.doi)
+		}
+	}
+
+	if !SameDoi("https://doi.org/10.1371/JOURNAL.PONE.0001", "10.1371/journal.pone.0001") {
+		t.Error("dois differing in prefix and case should match")
+	}
+	if SameDoi("", "") {
+		t.Error("empty dois should not match")
+	}
+}
+
+func TestRelatedArticleDoi(t *testing.T) {
+	article := parseArticle(t, `<article><front><article-meta>`+
+		`<related-article related-article-type="retracted-article" ext-link-type="doi" xlink:href="https://doi.org/10.1371/journal.pone.0001"/>`+
+		`<related-article related-article-type="corrected-article" xlink:href="doi:10.1371/journal.pone.0002"/>`+
+		`</article-meta></front></article>`)
+
+	if len(article.RelatedArticles) != 2 {
+		t.Fatalf("%d related articles, want 2", len(article.RelatedArticles))
+	}
+	for i, doi := range []string{"10.1371/journal.pone.0001", "10.1371/journal.pone.0002"} {
+		if related := article.RelatedArticles[i]; related.Doi != doi {
+			t.Errorf("related %d: doi %q, want %q", i, related.Doi, doi)
+		}
+	}
+	if article.Status != "" {
+		t.Errorf("status %q, a notice is not retracted itself", article.Status)
+	}
+}
+
+func TestArticleDoiKey(t *testing.T) {
+	article := parseArticle(t, `<article><front><article-meta>`+
+		`<article-id pub-id-type="doi">https://doi.org/10.1/ABC</article-id>`+
+		`<related-article related-article-type="corrected-article" ext-link-type="doi" xlink:href="doi:10.2/XYZ"/>`+
+		`</article-meta></front></article>`)
+
+	if article.Doi != "10.1/ABC" || article.DoiKey != "10.1/abc" {
+		t.Errorf("doi %q, key %q, want %q and %q", article.Doi, article.DoiKey, "10.1/ABC", "10.1/abc")
+	}
+	if related := article.RelatedArticles[0]; related.DoiKey != "10.2/xyz" {
+		t.Errorf("related doi key %q, want %q", related.DoiKey, "10.2/xyz")
+	}
+	if key := DoiKey("HTTP://DX.DOI.ORG/10.1/Abc"); key != article.DoiKey {
+		t.Errorf("DoiKey = %q, want %q", key, article.DoiKey)
+	}
+}
@@ -0,0 +1,533 @@
+package models
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	htmlName      = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+	attributeName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+)
+
+// tags the renderer may write, an element policy mapping to anything else is unwrapped
+var AllowedTags = map[string]bool{
+	"div": true, "span": true, "p": true, "a": true, "img": true, "br": true, "hr": true, "mark": true,
+	"h2": true, "h3": true, "h4": true, "i": true, "b": true, "u": true, "s": true, "code": true, "pre": true,
+	"sub": true, "sup": true, "ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"blockquote": true, "table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "th": true,
+	"td": true, "col": true, "colgroup": true,
+	"fig": true, //hidden, picked up by the figure side panel
+}
+
+// attributes the renderer may write, besides data-*
+var AllowedAttributes = map[string]bool{
+	"id": true, "class": true, "style": true, "href": true, "src": true, "alt": true, "title": true,
+	"colspan": true, "rowspan": true, "span": true, "align": true, "valign": true, "scope": true, "width": true,
+	"rel": true, "target": true,
+}
+
+// attributes holding a url, checked against AllowedSchemes
+var UrlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "background": true, "poster": true,
+	"xlink:href": true, "definitionurl": true,
+}
+
+// schemes a rendered url may use; urls without a scheme are relative and always allowed
+var AllowedSchemes = map[string]bool{
+	"http": true, "https": true, "mailto": true, "ftp": true,
+}
+
+// jats attributes copied onto the rendered element, they mean the same in html
+var PassAttributes = map[string][]string{
+	"fig":                    {"id"},
+	"table-wrap":             {"id"},
+	"disp-formula":           {"id"},
+	"fn":                     {"id"},
+	"app":                    {"id"},
+	"boxed-text":             {"id"},
+	"supplementary-material": {"id"},
+	"td":                     {"colspan", "rowspan", "align", "valign"},
+	"th":                     {"colspan", "rowspan", "align", "valign", "scope"},
+	"col":                    {"span", "width", "align", "valign"},
+	"colgroup":               {"span", "width", "align", "valign"},
+}
+
+func IsAllowedTag(tag string) bool {
+	return htmlName.MatchString(tag) && AllowedTags[tag]
+}
+
+func IsAllowedAttribute(name string) bool {
+	if !attributeName.MatchString(name) || IsEventAttribute(name) {
+		return false
+	}
+	return AllowedAttributes[name] || (strings.HasPrefix(name, "data-") && len(name) > len("data-"))
+}
+
+func IsEventAttribute(name string) bool {
+	//onclick, onload and the rest run script, which our content security policy forbids
+	return strings.HasPrefix(strings.ToLower(name), "on")
+}
+
+func SafeUrl(raw string) (safe string, ok bool) {
+	//browsers ignore whitespace and control characters inside a scheme, so "java\tscript:" must not slip through
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, raw)
+	if cleaned == "" {
+		return "", false
+	}
+	u, err := url.Parse(cleaned)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !AllowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	first := cleaned
+	if i := strings.IndexAny(first, "/?#"); i >= 0 {
+		first = first[:i]
+	}
+	if u.Scheme == "" && strings.Contains(first, ":") {
+		//an unparsed scheme, e.g. "javascript&colon;"
+		return "", false
+	}
+	return strings.TrimSpace(raw), true
+}
+
+func ExtLinkUrl(extLinkType string, href string) string {
+	//url for an ext-link, dois and pubmed ids become resolver links; empty if unsafe
+	href = strings.TrimSpace(href)
+	switch extLinkType {
+	case "doi":
+		if !strings.Contains(href, "://") {
+			href = "https://doi.org/" + strings.TrimPrefix(href, "doi:")
+		}
+	case "pmid", "pubmed":
+		if !strings.Contains(href, "://") {
+			href = "https://pubmed.ncbi.nlm.nih.gov/" + url.PathEscape(href) + "/"
+		}
+	}
+	safe, ok := SafeUrl(href)
+	if !ok {
+		return ""
+	}
+	return safe
+}
+
+func renderOpenTag(tag string, props map[string]string) (output string) {
+	//attributes in name order so the same node always renders the same
+	names := []string{}
+	for name, val := range props {
+		if !IsAllowedAttribute(name) {
+			continue
+		}
+		if _, ok := SafeUrl(val); UrlAttributes[name] && !ok {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	output = "<" + tag
+	for _, name := range names {
+		output += " " + name + "=\"" + html.EscapeString(props[name]) + "\""
+	}
+	output += ">"
+	return
+}
+
+// the element about to be written, for an ElementTransform to change
+type RenderElement struct {
+	Tag    string
+	Props  map[string]string
+	Prefix string //text before the children, escaped when written
+	Suffix string
+}
+
+type ElementTransform func(node Node, state *RenderState, element *RenderElement)
+
+// returns false to render the element by its policy after all
+type ElementRender func(node Node, state *RenderState) (output string, ok bool)
+
+type RenderState struct {
+	Depth int    //nesting of the element, 1 at the top level
+	Pmc   string //for image urls
+
+	renderer  *Renderer
+	sentences []Sentence //of the enclosing block
+	offset    *int       //UTF-16 units of the block's text rendered so far
+	marks     map[string][]Annotation
+}
+
+func (this *RenderState) RenderChildren(node Node) string {
+	//for an ElementRender that wraps the children itself
+	return this.renderer.renderNodes(node.Children, *this)
+}
+
+func (this *RenderState) inner(node Node) (inner RenderState) {
+	//inline elements share the sentences of their block, which are offset over its flattened text
+	inner = *this
+	if !IsInlineTag(node.Tag) {
+		inner.sentences, inner.offset = node.Sentences, new(int)
+	}
+	return
+}
+
+// Renderer turns article nodes into html. Policies start from ElementPolicies; a frontend
+// replaces entries to change tags, classes or attributes without touching the traversal.
+// It replaces ArticleParseNodes, which rendered any nodes without checking the article's
+// access and was removed; use Article.RenderBody or Renderer.RenderBody instead.
+type Renderer struct {
+	Policies      map[string]ElementPolicy
+	Default       ElementPolicy //for elements without a policy
+	SentenceClass string        //spans around sentences, none if empty
+	MarkClass     string        //marks around annotated ranges
+}
+
+// used by the Article Render methods
+var DefaultRenderer = NewWebRenderer()
+
+func NewRenderer(policies map[string]ElementPolicy) *Renderer {
+	renderer := &Renderer{
+		Policies:      map[string]ElementPolicy{},
+		Default:       DefaultElementPolicy,
+		SentenceClass: "ae-sentence",
+		MarkClass:     "ae-annotation",
+	}
+	for element, policy := range ElementPolicies {
+		renderer.Policies[element] = policy
+	}
+	for element, policy := range policies {
+		renderer.Policies[element] = policy
+	}
+	return renderer
+}
+
+func NewWebRenderer() *Renderer {
+	//the reading view, classes match the site stylesheet
+	return NewRenderer(map[string]ElementPolicy{
+		"title":          {Action: ElementMap, Tag: "h2", Transform: HeadingTransform},
+		"sec":            {Action: ElementMap, Tag: "div", Class: "ae-paragraph"},
+		"p":              {Action: ElementMap, Tag: "div", Class: "ae-paragraph"},
+		"xref":           {Action: ElementMap, Tag: "a", Class: "article-additional", Transform: XrefTransform},
+		"ext-link":       {Action: ElementMap, Tag: "a", Class: "long-word", Transform: ExtLinkTransform},
+		"inline-formula": {Action: ElementMap, Tag: "span", Render: FormulaRender("")},
+		"disp-formula":   {Action: ElementMap, Tag: "div", Class: "mobile-small ae-paragraph", Render: FormulaRender("mobile-small ae-paragraph")},
+		"mml:math":       {Action: ElementUnwrap, Render: FormulaRender("")},
+		"math":           {Action: ElementUnwrap, Render: FormulaRender("")},
+		"tex-math":       {Action: ElementUnwrap, Render: FormulaRender("")},
+		"inline-graphic": {Action: ElementMap, Tag: "img", Transform: GraphicTransform},
+		"graphic":        {Action: ElementMap, Tag: "img", Transform: GraphicTransform},
+		"fig":            {Action: ElementKeep, Transform: HiddenTransform},
+	})
+}
+
+func NewDigestRenderer() *Renderer {
+	//email digests: no sentence spans, citations or figures, and TeX instead of MathML, which mail clients can't show
+	renderer := NewRenderer(map[string]ElementPolicy{
+		"title":          {Action: ElementMap, Tag: "h3"},
+		"sec":            {Action: ElementMap, Tag: "div"},
+		"p":              {Action: ElementMap, Tag: "p"},
+		"xref":           {Action: ElementDrop},
+		"ext-link":       {Action: ElementMap, Tag: "a", Transform: ExtLinkTransform},
+		"inline-formula": {Action: ElementDrop, Render: TexRender},
+		"disp-formula":   {Action: ElementDrop, Render: TexRender},
+		"fig":            {Action: ElementDrop},
+		"table-wrap":     {Action: ElementDrop},
+		"inline-graphic": {Action: ElementDrop},
+		"graphic":        {Action: ElementDrop},
+	})
+	renderer.SentenceClass = ""
+	return renderer
+}
+
+func (this *Renderer) Policy(element string) ElementPolicy {
+	policy, found := this.Policies[element]
+	if !found {
+		return this.Default
+	}
+	return policy
+}
+
+// nodes are only rendered through the Render methods, which check the article's access first
+
+func (this *Renderer) renderNodesAnnotated(nodes []Node, depth int, sentences []Sentence, pmc string, annotations []Annotation) (output string) {
+	//annotated ranges are wrapped in marks, orphaned annotations are left out
+	marks := map[string][]Annotation{}
+	for _, annotation := range annotations {
+		if !annotation.Orphaned {
+			marks[annotation.SentenceId] = append(marks[annotation.SentenceId], annotation)
+		}
+	}
+	state := RenderState{
+		Depth:     depth,
+		Pmc:       pmc,
+		renderer:  this,
+		sentences: sentences,
+		offset:    new(int),
+		marks:     marks,
+	}
+	return this.renderNodes(nodes, state)
+}
+
+func (this *Renderer) RenderBody(article *Article, annotations []Annotation) (output string, access Access) {
+	access = article.Access()
+	if !access.Allows(AccessFull) {
+		return
+	}
+	article.AssignSectionIds()
+	article.AssignSentenceIds()
+	output = this.renderNodesAnnotated(article.Body, 0, nil, article.Pmc, annotations)
+	return
+}
+
+func (this *Renderer) RenderAbstract(article *Article, abstractType string, lang string) (output string, access Access) {
+	access = article.Access()
+	if !access.Allows(AccessAbstract) {
+		return
+	}
+	abstract, found := article.AbstractByType(abstractType, lang)
+	if !found {
+		return
+	}
+	output = this.renderNodesAnnotated(abstract.Children, 0, nil, article.Pmc, nil)
+	return
+}
+
+func (this *Renderer) RenderSubArticles(article *Article) (outputs []string, access Access) {
+	access = article.Access()
+	if !access.Allows(AccessFull) {
+		return
+	}
+	outputs = this.renderSubArticles(article.SubArticles, article.Pmc)
+	return
+}
+
+func (this *Renderer) renderSubArticles(subs []Article, pmc string) (outputs []string) {
+	//each sub-article rendered on its own, images resolve against the parent pmc
+	for _, sub := range subs {
+		output := "<div class=\"ae-sub-article\" data-article-type=\"" + html.EscapeString(sub.Type) + "\">"
+		if sub.Title.Text != "" {
+			output += "<h2>" + html.EscapeString(sub.Title.Text) + "</h2>"
+		}
+		output += this.renderNodesAnnotated(sub.Body, 0, nil, pmc, nil)
+		output += "</div>"
+		outputs = append(outputs, output)
+		outputs = append(outputs, this.renderSubArticles(sub.SubArticles, pmc)...)
+	}
+	return
+}
+
+func (this *Renderer) renderNodes(nodes []Node, state RenderState) (output string) {
+	state.Depth++
+	for _, node := range nodes {
+		if node.Type == "text" {
+			output += this.renderSentences(node.Body, state.sentences, *state.offset, state.marks)
+			*state.offset += Utf16Len(node.Body)
+		} else if node.Type == "tag" {
+			output += this.renderElement(node, state)
+		}
+	}
+	return
+}
+
+func (this *Renderer) renderElement(node Node, state RenderState) (output string) {
+	inner := state.inner(node)
+	policy := this.Policy(node.Tag)
+
+	if policy.Render != nil {
+		start := *inner.offset
+		rendered, ok := policy.Render(node, &inner)
+		//whatever the render func did, the block's text continues after this element
+		*inner.offset = start
+		if ok {
+			skipSentences(node, inner.offset)
+			return rendered
+		}
+	}
+
+	switch policy.Action {
+	case ElementDrop:
+		skipSentences(node, inner.offset)
+		return
+	case ElementUnwrap:
+		inner.Depth--
+		return this.renderNodes(node.Children, inner)
+	}
+
+	element := RenderElement{Tag: node.Tag, Props: map[string]string{}}
+	if policy.Action == ElementMap {
+		element.Tag = policy.Tag
+	}
+	for _, name := range PassAttributes[node.Tag] {
+		if val, found := node.Props[name]; found {
+			element.Props[name] = val
+		}
+	}
+	if policy.Class != "" {
+		element.Props["class"] = policy.Class
+	}
+	if policy.Transform != nil {
+		policy.Transform(node, &inner, &element)
+	}
+
+	if !IsAllowedTag(element.Tag) {
+		inner.Depth--
+		return this.renderNodes(node.Children, inner)
+	}
+	output += renderOpenTag(element.Tag, element.Props)
+	if voidTags[element.Tag] {
+		skipSentences(node, inner.offset)
+		return
+	}
+	output += html.EscapeString(element.Prefix)
+	output += this.renderNodes(node.Children, inner)
+	output += html.EscapeString(element.Suffix)
+	output += "</" + element.Tag + ">"
+	return
+}
+
+func skipSentences(node Node, offset *int) {
+	//account for the text of an inline element that isn't rendered
+	if IsInlineTag(node.Tag) {
+		*offset += Utf16Len(inlineText(node.Children))
+	}
+}
+
+func (this *Renderer) renderSentences(body string, sentences []Sentence, offset int, marks map[string][]Annotation) (output string) {
+	//wrap the parts of body that fall in each sentence, offset is where body starts in the block
+	first, last := 0, len(body)
+	if strings.HasPrefix(body, ")") {
+		first = 1
+	}
+	if strings.HasSuffix(body, "(") && last > first {
+		last--
+	}
+
+	//offsets are UTF-16 units, converting to bytes keeps every cut on a code point boundary
+	pos := first
+	for i, sent := range sentences {
+		start := ByteOffset(body, sent.Start-offset)
+		end := ByteOffset(body, sent.End-offset)
+		if sent.End-offset <= 0 {
+			continue
+		}
+		if start < pos {
+			start = pos
+		}
+		if end > last {
+			end = last
+		}
+		if end <= start {
+			continue
+		}
+		output += html.EscapeString(body[pos:start])
+		fragment := this.renderMarks(body, start, end, offset, sent, marks[sent.Id])
+		if this.SentenceClass != "" {
+			id := sent.Id
+			if id == "" {
+				id = strconv.Itoa(i)
+			}
+			//fragments of one sentence share the id, so it's a data attribute rather than an id
+			fragment = "<span class=\"" + html.EscapeString(this.SentenceClass) + "\" data-sentence-id=\"" + html.EscapeString(id) + "\">" + fragment + "</span>"
+		}
+		output += fragment
+		pos = end
+	}
+	output += html.EscapeString(body[pos:last])
+	return
+}
+
+func HeadingTransform(node Node, state *RenderState, element *RenderElement) {
+	//h2 to h4 by nesting, with the section id as an anchor
+	if node.Props["section-id"] != "" {
+		element.Props["id"] = node.Props["section-id"]
+	}
+	if state.Depth < 3 {
+		element.Tag = "h2"
+	} else if state.Depth < 4 {
+		element.Tag = "h3"
+	} else {
+		element.Tag = "h4"
+	}
+}
+
+func XrefTransform(node Node, state *RenderState, element *RenderElement) {
+	//the frontend opens the addition from the data attributes, the fragment works without it
+	if rids := strings.Fields(node.Props["rid"]); len(rids) > 0 {
+		element.Props["href"] = "#" + rids[0]
+		element.Props["data-rid"] = strings.Join(rids, " ")
+	}
+	if node.Props["ref-type"] != "" {
+		element.Props["data-ref-type"] = node.Props["ref-type"]
+	}
+	element.Props["data-addition-id"] = "citation"
+	element.Prefix, element.Suffix = "[", "]"
+}
+
+func ExtLinkTransform(node Node, state *RenderState, element *RenderElement) {
+	if href := ExtLinkUrl(node.Props["ext-link-type"], node.Props["xlink:href"]); href != "" {
+		element.Props["href"] = href
+		element.Props["rel"] = "noopener noreferrer"
+		element.Props["target"] = "_blank"
+	}
+	element.Props["data-addition-id"] = "citation"
+}
+
+func GraphicTransform(node Node, state *RenderState, element *RenderElement) {
+	//http://www.ncbi.nlm.nih.gov/pmc/articles/PMC3592458/bin/gks981i3.jpg
+	src := "http://www.ncbi.nlm.nih.gov/pmc/articles/PMC" + url.PathEscape(state.Pmc) + "/bin/" + url.PathEscape(node.Props["xlink:href"])
+	if node.Tag == "graphic" {
+		src += ".jpg"
+	}
+	element.Props["src"] = src
+}
+
+func HiddenTransform(node Node, state *RenderState, element *RenderElement) {
+	//is a figure - need to import figure into side panel
+	element.Props["style"] = "display:none;"
+}
+
+func FormulaRender(displayClass string) ElementRender {
+	//MathML, with the TeX as an annotation; display formulas get their label and a div with displayClass
+	return func(node Node, state *RenderState) (output string, ok bool) {
+		mathml, tex := node.MathML, node.Tex
+		if IsFormulaTag(node.Tag) {
+			mathml, tex = node.Formula()
+		}
+		math := ArticleRenderMath(mathml, tex, node.Tag == "disp-formula")
+		if math == "" {
+			return
+		}
+		if node.Tag != "disp-formula" {
+			return "<span>" + math + "</span>", true
+		}
+		for _, child := range node.Children {
+			if child.Tag == "label" {
+				math += " " + html.EscapeString(NodesText(child.Children))
+			}
+		}
+		return "<div class=\"" + html.EscapeString(displayClass) + "\">" + math + "</div>", true
+	}
+}
+
+func TexRender(node Node, state *RenderState) (output string, ok bool) {
+	//the formula's TeX as plain text, for clients without MathML
+	_, tex := node.Formula()
+	if tex == "" {
+		return
+	}
+	if node.Tag == "disp-formula" {
+		return "<pre>" + html.EscapeString(TexMathBody(tex)) + "</pre>", true
+	}
+	return "<code>" + html.EscapeString(TexMathBody(tex)) + "</code>", true
+}
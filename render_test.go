@@ -0,0 +1,70 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func parseArticle(t *testing.T, src string) Article {
+	doc, err := html.Parse(bytes.NewReader(ArticlePrepareXml([]byte(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	article, _, err := ArticleParse(doc, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return article
+}
+
+func TestTitleMarkup(t *testing.T) {
+	article := parseArticle(t, `<article><body><sec id="s1"><title>Role of <italic>p53</italic> in cells</title><p>Text.</p></sec></body></article>`)
+
+	title := article.Body[0].Children[0]
+	if title.Tag != "title" {
+		t.Fatalf("first child of the section is %q, want title", title.Tag)
+	}
+	output := DefaultRenderer.renderNodesAnnotated(article.Body, 0, nil, "1", nil)
+	if strings.Contains(output, "&lt;italic") {
+		t.Errorf("title markup rendered as text: %s", output)
+	}
+	if !strings.Contains(output, "<i><span class=\"ae-sentence\"") {
+		t.Errorf("italic in the title not rendered: %s", output)
+	}
+}
+
+func TestRenderEscaping(t *testing.T) {
+	article := parseArticle(t, `<article><body><sec><p>a &lt;script&gt; &amp; "q" <ext-link xlink:href="javascript:alert(1)">x</ext-link> <xref rid="B1" onclick="x">1</xref></p></sec></body></article>`)
+	output := DefaultRenderer.renderNodesAnnotated(article.Body, 0, nil, "1", nil)
+	for _, unwanted := range []string{"<script", "javascript:", "onclick"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("output contains %q: %s", unwanted, output)
+		}
+	}
+	if !strings.Contains(output, "&lt;script&gt; &amp; &#34;q&#34;") {
+		t.Errorf("text not escaped: %s", output)
+	}
+	if !strings.Contains(output, `href="#B1"`) {
+		t.Errorf("xref without an anchor: %s", output)
+	}
+}
+
+func TestFigureCaption(t *testing.T) {
+	article := parseArticle(t, `<article><body><sec><fig id="f1"><label>Figure 1</label><caption><title>Growth</title><p>Cells <italic>in vitro</italic>.</p></caption></fig></sec></body></article>`)
+
+	fig := article.Body[0].Children[0]
+	tags := []string{}
+	for _, child := range fig.Children {
+		tags = append(tags, child.Tag)
+	}
+	if strings.Join(tags, " ") != "label caption" {
+		t.Fatalf("fig children %v, want label caption", tags)
+	}
+	output := DefaultRenderer.renderNodesAnnotated(article.Body, 0, nil, "1", nil)
+	if !strings.Contains(output, `<div class="caption">`) {
+		t.Errorf("caption not rendered with its policy: %s", output)
+	}
+}
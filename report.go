@@ -0,0 +1,129 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+type Diagnostic struct {
+	Path     string `bson:"path,omitempty" json:"path,omitempty"` //element path, e.g. article/front/article-meta/counts/page-count
+	Severity string `bson:"severity,omitempty" json:"severity,omitempty"`
+	Message  string `bson:"message,omitempty" json:"message,omitempty"`
+}
+
+var ErrNoArticle = errors.New("no article element")
+
+func (this Diagnostic) Error() string {
+	if this.Path == "" {
+		return this.Severity + ": " + this.Message
+	}
+	return this.Severity + " at " + this.Path + ": " + this.Message
+}
+
+type ParseReport struct {
+	Strict      bool         `bson:"strict,omitempty" json:"strict,omitempty"` //fail on the first error instead of collecting it
+	Diagnostics []Diagnostic `bson:"diagnostics,omitempty" json:"diagnostics,omitempty"`
+}
+
+func (this *ParseReport) Errors() (diagnostics []Diagnostic) {
+	for _, d := range this.Diagnostics {
+		if d.Severity == SeverityError {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return
+}
+
+func (this *ParseReport) Warnings() (diagnostics []Diagnostic) {
+	for _, d := range this.Diagnostics {
+		if d.Severity == SeverityWarning {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return
+}
+
+func (this *ParseReport) HasErrors() bool {
+	return len(this.Errors()) > 0
+}
+
+func ArticleParse(doc *html.Node, strict bool) (article Article, report ParseReport, err error) {
+	report.Strict = strict
+	article.report = &report
+	defer func() {
+		article.report = nil
+	}()
+
+	err = article.Parse(doc)
+	if err != nil {
+		return
+	}
+
+	if !article.parsed {
+		article.check(doc, ErrNoArticle)
+		err = ErrNoArticle
+		return
+	}
+	if article.Pmc == "" {
+		article.warn(doc, "no pmc article-id")
+	}
+	article.AssignSectionIds()
+	article.AssignSentenceIds()
+	article.ComputeCounts()
+
+	return
+}
+
+func NodePath(n *html.Node) string {
+	//element names from the document root down to n, without the html parser's wrappers
+	path := []string{}
+	for ; n != nil; n = n.Parent {
+		if n.Type != html.ElementNode || n.Data == "html" || n.Data == "body" || n.Data == "head" {
+			continue
+		}
+		path = append([]string{JatsName(n)}, path...)
+	}
+	return strings.Join(path, "/")
+}
+
+func (this *Article) check(n *html.Node, err error) error {
+	//record err against n; it is only returned when parsing strictly, or without a report
+	if err == nil {
+		return nil
+	}
+	if _, recorded := err.(Diagnostic); recorded {
+		return err
+	}
+
+	diagnostic := Diagnostic{
+		Path:     NodePath(n),
+		Severity: SeverityError,
+		Message:  err.Error(),
+	}
+	if this.report == nil {
+		return diagnostic
+	}
+	this.report.Diagnostics = append(this.report.Diagnostics, diagnostic)
+	if this.report.Strict {
+		return diagnostic
+	}
+	return nil
+}
+
+func (this *Article) warn(n *html.Node, message string) {
+	if this.report == nil {
+		return
+	}
+	this.report.Diagnostics = append(this.report.Diagnostics, Diagnostic{
+		Path:     NodePath(n),
+		Severity: SeverityWarning,
+		Message:  message,
+	})
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const badCountXml = `<article><front><article-meta><counts><page-count count="twelve"/></counts>` +
+	`<volume>7</volume></article-meta></front></article>`
+
+func parseReport(t *testing.T, src string, strict bool) (Article, ParseReport, error) {
+	doc, err := html.Parse(bytes.NewReader(ArticlePrepareXml([]byte(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ArticleParse(doc, strict)
+}
+
+func TestParseReportStrict(t *testing.T) {
+	_, report, err := parseReport(t, badCountXml, true)
+	if err == nil {
+		t.Fatal("strict parse of a bad count succeeded")
+	}
+	diagnostic, ok := err.(Diagnostic)
+	if !ok {
+		t.Fatalf("error %T, want a Diagnostic", err)
+	}
+	if diagnostic.Path != "article/front/article-meta/counts/page-count" || diagnostic.Severity != SeverityError {
+		t.Errorf("error %q, want an error at article/front/article-meta/counts/page-count", diagnostic.Error())
+	}
+	if !report.HasErrors() {
+		t.Error("strict report has no errors")
+	}
+}
+
+func TestParseReportCollects(t *testing.T) {
+	article, report, err := parseReport(t, badCountXml, false)
+	if err != nil {
+		t.Fatalf("non-strict parse failed: %v", err)
+	}
+	errors := report.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("%d errors, want 1: %v", len(errors), report.Diagnostics)
+	}
+	if errors[0].Path != "article/front/article-meta/counts/page-count" {
+		t.Errorf("error at %q, want article/front/article-meta/counts/page-count", errors[0].Path)
+	}
+	if article.Volume != "7" {
+		t.Errorf("volume %q after the bad count, want %q", article.Volume, "7")
+	}
+
+	//a missing pmc is only a warning
+	found := false
+	for _, d := range report.Warnings() {
+		if d.Message == "no pmc article-id" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no warning about the missing pmc: %v", report.Diagnostics)
+	}
+}
+
+func TestParseReportNoArticle(t *testing.T) {
+	_, report, err := parseReport(t, `<div>not jats</div>`, false)
+	if err != ErrNoArticle {
+		t.Errorf("error %v, want %v", err, ErrNoArticle)
+	}
+	if !report.HasErrors() {
+		t.Error("no error recorded for a document without an article")
+	}
+}
@@ -0,0 +1,286 @@
+package models
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+const (
+	SectionIntro               = "intro"
+	SectionMethods             = "methods"
+	SectionResults             = "results"
+	SectionDiscussion          = "discussion"
+	SectionConclusions         = "conclusions"
+	SectionDataAvailability    = "data-availability"
+	SectionConflictOfInterest  = "conflict-of-interest"
+	SectionEthics              = "ethics"
+	SectionAuthorContributions = "author-contributions"
+)
+
+type sectionRule struct {
+	kind     string
+	secTypes []string
+	titles   []string
+}
+
+// checked in order, so the specific statements win over "methods" or "results" in a title
+var sectionRules = []sectionRule{
+	{SectionDataAvailability, []string{"data-availability", "data-access"}, []string{"data availability", "availability of data", "data access", "data and code availability", "code availability", "data deposition"}},
+	{SectionConflictOfInterest, []string{"coi-statement", "conflict", "competing-interests"}, []string{"conflict of interest", "conflicts of interest", "competing interest", "declaration of interest", "declarations of interest", "disclosure"}},
+	{SectionEthics, []string{"ethics-statement", "ethics"}, []string{"ethics", "ethical", "institutional review board", "informed consent"}},
+	{SectionAuthorContributions, []string{"author-contributions", "contributions", "con"}, []string{"author contribution", "authors' contribution", "authors contribution", "contributorship"}},
+	{SectionIntro, []string{"intro", "introduction", "background"}, []string{"introduction", "background"}},
+	{SectionMethods, []string{"methods", "materials", "materials|methods", "subjects", "cases"}, []string{"method", "materials and", "experimental procedure", "experimental section", "study design", "patients and"}},
+	{SectionResults, []string{"results", "results|discussion"}, []string{"result", "findings"}},
+	{SectionDiscussion, []string{"discussion"}, []string{"discussion"}},
+	{SectionConclusions, []string{"conclusions", "conclusion"}, []string{"conclusion", "concluding"}},
+}
+
+type Section struct {
+	Id       string    `bson:"id,omitempty" json:"id,omitempty"`
+	SecType  string    `bson:"secType,omitempty" json:"secType,omitempty"`
+	Label    string    `bson:"label,omitempty" json:"label,omitempty"`
+	Title    string    `bson:"title,omitempty" json:"title,omitempty"`
+	Depth    int       `bson:"depth,omitempty" json:"depth,omitempty"` //1 for top level sections
+	Kind     string    `bson:"kind,omitempty" json:"kind,omitempty"`   //one of the Section* constants, empty if unclassified
+	Words    int       `bson:"words,omitempty" json:"words,omitempty"` //including subsections
+	Children []Section `bson:"children,omitempty" json:"children,omitempty"`
+	Back     bool      `bson:"back,omitempty" json:"back,omitempty"` //from the back matter, see Article.Back
+	Node     Node      `bson:"-" json:"-"`                           //the sec, notes or fn node, for rendering the section content
+}
+
+func (this *Article) AssignSectionIds() {
+	//sections without an id attribute get one from their position, e.g. sec-2-1 or back-1
+	assignSectionIds(this.Body, "sec")
+	assignSectionIds(this.Back, "back")
+}
+
+func assignSectionIds(nodes []Node, prefix string) {
+	index := 0
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Type != "tag" || node.Tag != "sec" {
+			continue
+		}
+		index++
+		if node.Props == nil {
+			node.Props = map[string]string{}
+		}
+		id := node.Props["id"]
+		if id == "" {
+			id = prefix + "-" + strconv.Itoa(index)
+			node.Props["id"] = id
+		}
+		//headings carry the id of their section as an anchor
+		for j := range node.Children {
+			child := &node.Children[j]
+			if child.Type == "tag" && child.Tag == "title" {
+				if child.Props == nil {
+					child.Props = map[string]string{}
+				}
+				child.Props["section-id"] = id
+				break
+			}
+		}
+		assignSectionIds(node.Children, prefix+"-"+strconv.Itoa(index))
+	}
+}
+
+func (this *Article) Sections() (sections []Section) {
+	//body sections, then the sections, notes and footnotes of the back matter
+	this.AssignSectionIds()
+	sections = nodeSections(this.Body, 1)
+	for _, section := range backSections(this.Back) {
+		markBack(&section)
+		sections = append(sections, section)
+	}
+	return
+}
+
+func backSections(nodes []Node) (sections []Section) {
+	for _, node := range nodes {
+		switch node.Tag {
+		case "sec":
+			sections = append(sections, nodeSections([]Node{node}, 1)...)
+		case "notes":
+			section := noteSection(node, node.Props["notes-type"])
+			section.Children = nodeSections(node.Children, 2)
+			sections = append(sections, section)
+		case "fn-group":
+			//footnotes typed as statements, e.g. fn-type="COI-statement" or "con"
+			for _, fn := range node.Children {
+				if fn.Type == "tag" && fn.Tag == "fn" {
+					sections = append(sections, noteSection(fn, fn.Props["fn-type"]))
+				}
+			}
+		}
+	}
+	return
+}
+
+func noteSection(node Node, secType string) (section Section) {
+	section = Section{
+		Id:      node.Props["id"],
+		SecType: secType,
+		Depth:   1,
+		Node:    node,
+	}
+	for _, child := range node.Children {
+		if child.Tag == "title" && section.Title == "" {
+			section.Title = NodesText(child.Children)
+		} else if child.Tag == "label" && section.Label == "" {
+			section.Label = NodesText(child.Children)
+		}
+	}
+	section.Kind = ClassifySection(section.SecType, section.Title)
+	section.Words = NodesStats([]Node{node}).Words
+	return
+}
+
+func markBack(section *Section) {
+	section.Back = true
+	for i := range section.Children {
+		markBack(&section.Children[i])
+	}
+}
+
+func nodeSections(nodes []Node, depth int) (sections []Section) {
+	for _, node := range nodes {
+		if node.Type != "tag" || node.Tag != "sec" {
+			continue
+		}
+		section := Section{
+			Id:      node.Props["id"],
+			SecType: node.Props["sec-type"],
+			Depth:   depth,
+		}
+		for _, child := range node.Children {
+			if child.Tag == "title" && section.Title == "" {
+				section.Title = NodesText(child.Children)
+			} else if child.Tag == "label" && section.Label == "" {
+				section.Label = NodesText(child.Children)
+			}
+		}
+		section.Kind = ClassifySection(section.SecType, section.Title)
+		section.Words = NodesStats([]Node{node}).Words
+		section.Node = node
+		section.Children = nodeSections(node.Children, depth+1)
+		sections = append(sections, section)
+	}
+	return
+}
+
+func (this *Article) TableOfContents() (toc []Section) {
+	//the body's section tree without untitled sections, their subsections move up a level
+	body := []Section{}
+	for _, section := range this.Sections() {
+		if !section.Back {
+			body = append(body, section)
+		}
+	}
+	return tocSections(body)
+}
+
+func tocSections(sections []Section) (toc []Section) {
+	for _, section := range sections {
+		children := tocSections(section.Children)
+		if section.Title == "" {
+			toc = append(toc, children...)
+			continue
+		}
+		section.Children = children
+		toc = append(toc, section)
+	}
+	return
+}
+
+func (this *Article) RenderTableOfContents() (output string, access Access) {
+	//the headings are body text, so the table of contents needs full access too
+	access = this.Access()
+	if !access.Allows(AccessFull) {
+		return
+	}
+	output = renderTableOfContents(this.TableOfContents())
+	return
+}
+
+func renderTableOfContents(toc []Section) (output string) {
+	if len(toc) == 0 {
+		return
+	}
+	output += "<ul class=\"ae-toc\">"
+	for _, section := range toc {
+		title := section.Title
+		if section.Label != "" {
+			title = section.Label + " " + title
+		}
+		output += "<li><a href=\"#" + html.EscapeString(section.Id) + "\">" + html.EscapeString(title) + "</a>"
+		output += renderTableOfContents(section.Children)
+		output += "</li>"
+	}
+	output += "</ul>"
+	return
+}
+
+func (this *Article) SectionById(id string) (section Section, found bool) {
+	return findSection(this.Sections(), id)
+}
+
+func findSection(sections []Section, id string) (section Section, found bool) {
+	for _, s := range sections {
+		if s.Id == id {
+			return s, true
+		}
+		if section, found = findSection(s.Children, id); found {
+			return
+		}
+	}
+	return
+}
+
+func ClassifySection(secType string, title string) string {
+	//sec-type first, it is the publisher's own classification
+	secType = strings.ToLower(strings.TrimSpace(secType))
+	if secType != "" {
+		for _, rule := range sectionRules {
+			for _, t := range rule.secTypes {
+				if secType == t {
+					return rule.kind
+				}
+			}
+		}
+	}
+
+	title = strings.ToLower(title)
+	if title == "" {
+		return ""
+	}
+	for _, rule := range sectionRules {
+		for _, t := range rule.titles {
+			if strings.Contains(title, t) {
+				return rule.kind
+			}
+		}
+	}
+	return ""
+}
+
+func (this *Article) SectionByKind(kind string) (section Section, found bool) {
+	//outermost first, so a nested "ethics statement" in the methods doesn't hide the methods
+	return findSectionKind(this.Sections(), kind)
+}
+
+func findSectionKind(sections []Section, kind string) (section Section, found bool) {
+	for _, s := range sections {
+		if s.Kind == kind {
+			return s, true
+		}
+	}
+	for _, s := range sections {
+		if section, found = findSectionKind(s.Children, kind); found {
+			return
+		}
+	}
+	return
+}
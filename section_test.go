@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSectionTitleMarkup(t *testing.T) {
+	article := parseArticle(t, `<article><body><sec><title>Role of <italic>p53</italic> in cells</title><p>Two words.</p></sec></body></article>`)
+
+	sections := article.Sections()
+	if len(sections) != 1 {
+		t.Fatalf("got %d sections, want 1", len(sections))
+	}
+	if sections[0].Title != "Role of p53 in cells" {
+		t.Errorf("Title = %q, want %q", sections[0].Title, "Role of p53 in cells")
+	}
+	//five in the title, two in the paragraph
+	if sections[0].Words != 7 {
+		t.Errorf("Words = %d, want 7", sections[0].Words)
+	}
+	article.Permissions.Licenses = []string{"open-access"}
+	toc, _ := article.RenderTableOfContents()
+	if !strings.Contains(toc, ">Role of p53 in cells</a>") {
+		t.Errorf("table of contents: %s", toc)
+	}
+}
+
+func TestBackMatterSections(t *testing.T) {
+	article := parseArticle(t, `<article><body><sec sec-type="methods"><title>Methods</title><p>We did it.</p></sec></body>
+		<back><ack><p>Thanks.</p></ack>
+		<sec sec-type="data-availability"><title>Data availability</title><p>On request.</p></sec>
+		<notes notes-type="ethics-statement"><p>Approved.</p></notes>
+		<fn-group><fn fn-type="COI-statement"><p>None declared.</p></fn><fn fn-type="con"><p>All authors wrote it.</p></fn></fn-group>
+		<ref-list><ref id="B1"><element-citation><source>J</source></element-citation></ref></ref-list></back></article>`)
+
+	tests := []struct {
+		kind string
+		text string
+	}{
+		{SectionMethods, "We did it."},
+		{SectionDataAvailability, "On request."},
+		{SectionEthics, "Approved."},
+		{SectionConflictOfInterest, "None declared."},
+		{SectionAuthorContributions, "All authors wrote it."},
+	}
+	for _, test := range tests {
+		section, found := article.SectionByKind(test.kind)
+		if !found {
+			t.Errorf("SectionByKind(%q) not found", test.kind)
+			continue
+		}
+		if text := NodesText(section.Node.Children); !strings.Contains(text, test.text) {
+			t.Errorf("SectionByKind(%q) text = %q, want %q", test.kind, text, test.text)
+		}
+	}
+
+	if len(article.Ack) == 0 || len(article.Refs.List) != 1 {
+		t.Errorf("ack or refs lost: %d ack nodes, %d refs", len(article.Ack), len(article.Refs.List))
+	}
+	for _, section := range article.TableOfContents() {
+		if section.Back {
+			t.Errorf("back matter section %q in the table of contents", section.Title)
+		}
+	}
+}
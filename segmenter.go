@@ -0,0 +1,296 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type Segmenter interface {
+	//Segment splits text into contiguous sentences covering all of it, with byte offsets
+	Segment(text string) []Sentence
+}
+
+// used by Node.GetSentences for text without a language, or one with no entry in Segmenters
+var DefaultSegmenter Segmenter = NewRuleSegmenter()
+
+// by primary language subtag of xml:lang, e.g. "zh" for zh-Hans
+var Segmenters = map[string]Segmenter{
+	"zh": NewCjkSegmenter(),
+	"ja": NewCjkSegmenter(),
+	"es": NewSpanishSegmenter(),
+}
+
+func SegmenterForLang(lang string) Segmenter {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if segmenter, found := Segmenters[lang]; found {
+		return segmenter
+	}
+	return DefaultSegmenter
+}
+
+type RuleSegmenter struct {
+	Terminators string //end a sentence
+	Closers     string //belong to the sentence they follow, e.g. closing quotes
+	Openers     string //may come before the first letter of a sentence, e.g. "¿"
+	//the terminators that only end a sentence when whitespace follows; not the full-width ones of
+	//scripts written without spaces
+	SpacedTerminators string
+	//never end a sentence, e.g. "Fig." or "Dr."
+	Abbreviations map[string]bool
+	//only end a sentence when the next word is capitalized, e.g. "et al." or "etc."; also
+	//abbreviations that are ordinary words too, so "No. 5" holds together but "or no. Then" splits;
+	//written capitalized, as in "Ms. Smith" or "J. Med. Chem.", a sentence starter must follow
+	SoftAbbreviations map[string]bool
+	//words that after a single capital show it ends a sentence, as in "vitamin A. Then", rather than being an initial
+	SentenceStarters map[string]bool
+}
+
+func NewRuleSegmenter() *RuleSegmenter {
+	segmenter := &RuleSegmenter{
+		Terminators:       ".?!",
+		Closers:           `"')]}”’»`,
+		Openers:           `"'([{“‘«¿¡`,
+		SpacedTerminators: ".?!",
+		Abbreviations:     map[string]bool{},
+		SoftAbbreviations: map[string]bool{},
+		SentenceStarters:  map[string]bool{},
+	}
+	for _, a := range strings.Fields(`fig figs eq eqs ref refs tab suppl sect chap
+		e.g i.e cf vs viz approx resp incl
+		dr mr mrs prof mt jr sr
+		subsp
+		proc natl acad sci biol chem phys lett`) {
+		segmenter.Abbreviations[a] = true
+	}
+	for _, a := range strings.Fields(`al etc inc ltd co corp dept univ
+		vol no nos pp p ca est st sp spp var gen nov
+		ms j med rev res
+		jan feb mar apr jun jul aug sep sept oct dec`) {
+		segmenter.SoftAbbreviations[a] = true
+	}
+	for _, w := range strings.Fields(`the then this that these those there thus we our it its in on at as
+		a an all each both for from to however here finally moreover furthermore therefore after when while
+		although since no not only one two next first second third results data`) {
+		segmenter.SentenceStarters[w] = true
+	}
+	return segmenter
+}
+
+func NewCjkSegmenter() *RuleSegmenter {
+	//chinese and japanese: full-width punctuation and no spaces between sentences; english
+	//passages without an xml:lang of their own keep the english rules
+	segmenter := NewRuleSegmenter()
+	segmenter.Terminators += "。！？"
+	segmenter.Closers += "」』）】〕》〉"
+	segmenter.Openers += "「『（【〔《〈"
+	segmenter.SpacedTerminators = "."
+	return segmenter
+}
+
+func NewSpanishSegmenter() *RuleSegmenter {
+	//questions and exclamations open with "¿" and "¡", already among the openers
+	segmenter := NewRuleSegmenter()
+	for _, a := range strings.Fields(`pág págs núm nº fig figs tab ref refs vol cap
+		p.ej ej aprox dr dra sr sra srta ud uds lic ing
+		ene abr ago dic`) {
+		segmenter.Abbreviations[a] = true
+	}
+	for _, a := range strings.Fields(`cols cía sa`) {
+		segmenter.SoftAbbreviations[a] = true
+	}
+	return segmenter
+}
+
+func (this *RuleSegmenter) Segment(text string) (sentences []Sentence) {
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !this.isTerminator(r) {
+			i += size
+			continue
+		}
+
+		//swallow "...", "?!" and the closing quotes, brackets or [12] citations after the terminator
+		end := i + size
+		for end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if this.isTerminator(next) || this.isCloser(next) {
+				end += nextSize
+				continue
+			}
+			if next == '[' {
+				if c := citationEnd(text[end:]); c > 0 {
+					end += c
+					continue
+				}
+			}
+			break
+		}
+
+		if this.isBoundary(text, i, r, end) {
+			sentences = append(sentences, Sentence{Start: start, End: end})
+			start = end
+		}
+		i = end
+	}
+
+	if start < len(text) || len(sentences) == 0 {
+		sentences = append(sentences, Sentence{Start: start, End: len(text)})
+	}
+	return
+}
+
+func (this *RuleSegmenter) isBoundary(text string, i int, terminator rune, end int) bool {
+	//a boundary needs whitespace after it, then something that can start a sentence
+	if end < len(text) && strings.ContainsRune(this.SpacedTerminators, terminator) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	next := this.nextWordStart(text[end:])
+	if next != 0 && unicode.IsLower(next) {
+		return false
+	}
+	if terminator != '.' {
+		return true
+	}
+
+	previous := previousWord(text[:i])
+	word := strings.ToLower(previous)
+	if word == "" {
+		return true
+	}
+	if this.Abbreviations[word] {
+		return false
+	}
+
+	//initials such as "J. Smith", unless a typical sentence start follows
+	starter := this.SentenceStarters[strings.ToLower(this.nextWord(text[end:]))]
+	capitalized := unicode.IsUpper([]rune(previous)[0])
+	if utf8.RuneCountInString(previous) == 1 && capitalized {
+		return starter
+	}
+	if this.SoftAbbreviations[word] {
+		if next == 0 {
+			return true
+		}
+		return unicode.IsUpper(next) && (!capitalized || starter)
+	}
+	return true
+}
+
+func (this *RuleSegmenter) isTerminator(r rune) bool {
+	return strings.ContainsRune(this.Terminators, r)
+}
+
+func (this *RuleSegmenter) isCloser(r rune) bool {
+	return strings.ContainsRune(this.Closers, r)
+}
+
+func citationEnd(text string) int {
+	//length of a leading numeric citation such as [12] or [3,4-6], 0 if there is none
+	for i, r := range text {
+		if i == 0 {
+			continue
+		}
+		if r == ']' {
+			if i == 1 {
+				return 0
+			}
+			return i + 1
+		}
+		if !unicode.IsDigit(r) && r != ',' && r != '-' && r != '–' && r != ' ' {
+			return 0
+		}
+	}
+	return 0
+}
+
+func previousWord(text string) string {
+	//the word immediately before the end of text, keeping inner dots as in "e.g"
+	end := len(text)
+	start := end
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !unicode.IsLetter(r) && r != '.' {
+			break
+		}
+		start -= size
+	}
+	return strings.Trim(text[start:end], ".")
+}
+
+func (this *RuleSegmenter) nextWord(text string) string {
+	//the letters of the word nextWordStart begins
+	start := -1
+	for i, r := range text {
+		if start < 0 {
+			if unicode.IsSpace(r) || strings.ContainsRune(this.Openers, r) {
+				continue
+			}
+			start = i
+		}
+		if !unicode.IsLetter(r) {
+			return text[start:i]
+		}
+	}
+	if start < 0 {
+		return ""
+	}
+	return text[start:]
+}
+
+func (this *RuleSegmenter) nextWordStart(text string) rune {
+	//first letter or digit after whitespace, opening quotes and brackets
+	for _, r := range text {
+		if unicode.IsSpace(r) || strings.ContainsRune(this.Openers, r) {
+			continue
+		}
+		return r
+	}
+	return 0
+}
+
+// Sentence offsets are in UTF-16 code units, the unit javascript strings index by.
+// Segmenters work on Go strings and return byte offsets, GetSentences converts them.
+
+func Utf16Len(s string) (n int) {
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return
+}
+
+func Utf16Offset(s string, byteOffset int) int {
+	//byte offset into s as a UTF-16 offset
+	if byteOffset > len(s) {
+		byteOffset = len(s)
+	}
+	return Utf16Len(s[:byteOffset])
+}
+
+func ByteOffset(s string, utf16Offset int) int {
+	//UTF-16 offset into s as a byte offset, moved forward to the end of any code point it falls inside
+	units := 0
+	for i, r := range s {
+		if units >= utf16Offset {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(s)
+}
+
+func RuneOffset(s string, utf16Offset int) int {
+	return utf8.RuneCountInString(s[:ByteOffset(s, utf16Offset)])
+}
+
+func SentenceText(body string, sent Sentence) string {
+	return body[ByteOffset(body, sent.Start):ByteOffset(body, sent.End)]
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func segment(segmenter Segmenter, text string) (sentences []string) {
+	for _, sent := range segmenter.Segment(text) {
+		sentences = append(sentences, text[sent.Start:sent.End])
+	}
+	return
+}
+
+func TestRuleSegmenter(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"plain", "One sentence. Another one.", []string{"One sentence.", " Another one."}},
+		{"et al. before a year", "As Smith et al. 2010 showed, it works. Next.", []string{"As Smith et al. 2010 showed, it works.", " Next."}},
+		{"et al. before a capital", "This was shown by Smith et al. The effect held.", []string{"This was shown by Smith et al.", " The effect held."}},
+		{"figure reference", "See Fig. 3 for details. Then stop.", []string{"See Fig. 3 for details.", " Then stop."}},
+		{"figure reference before a capital", "As in Fig. A shows. Next.", []string{"As in Fig. A shows.", " Next."}},
+		{"e.g.", "Some cells, e.g. neurons, died. Others lived.", []string{"Some cells, e.g. neurons, died.", " Others lived."}},
+		{"p value", "The difference was significant, p < 0.05. We then repeated it.", []string{"The difference was significant, p < 0.05.", " We then repeated it."}},
+		{"title", "Dr. Smith agreed. So did we.", []string{"Dr. Smith agreed.", " So did we."}},
+		{"initials", "Written by J. R. Smith in 2001. Later revised.", []string{"Written by J. R. Smith in 2001.", " Later revised."}},
+		{"capital ending a sentence", "We used vitamin A. Then B.", []string{"We used vitamin A.", " Then B."}},
+		{"number abbreviation", "Patient No. 5 recovered. Others did not.", []string{"Patient No. 5 recovered.", " Others did not."}},
+		{"common word ending a sentence", "It was either yes or no. Then we moved on.", []string{"It was either yes or no.", " Then we moved on."}},
+		{"unit ending a sentence", "Latency was 300 ms. The effect held.", []string{"Latency was 300 ms.", " The effect held."}},
+		{"month ending a sentence", "Samples were collected in Dec. The end.", []string{"Samples were collected in Dec.", " The end."}},
+		{"month before a day", "Collected on Dec. 5 in the field. Done.", []string{"Collected on Dec. 5 in the field.", " Done."}},
+		{"journal abbreviation", "Published in J. Med. Chem. in 2010. Done.", []string{"Published in J. Med. Chem. in 2010.", " Done."}},
+		{"title before a name", "Ms. Smith agreed. So did we.", []string{"Ms. Smith agreed.", " So did we."}},
+		{"question and exclamation", "Why did it fail? It did not! Really.", []string{"Why did it fail?", " It did not!", " Really."}},
+		{"ellipsis and ?!", "Wait... What?! Fine.", []string{"Wait...", " What?!", " Fine."}},
+		{"closing quote", `He said "stop." Then he left.`, []string{`He said "stop."`, " Then he left."}},
+		{"closing bracket", "It rose (see above.) The rest fell.", []string{"It rose (see above.)", " The rest fell."}},
+		{"citation after the period", "This is known.[12] New results follow.", []string{"This is known.[12]", " New results follow."}},
+		{"citation range", "Shown before.[3,4-6] Next.", []string{"Shown before.[3,4-6]", " Next."}},
+		{"lowercase continuation", "The value was 3.5 mg. per day in total.", []string{"The value was 3.5 mg. per day in total."}},
+		{"decimal", "It grew by 2.5 percent. Good.", []string{"It grew by 2.5 percent.", " Good."}},
+		{"no terminator", "A title without a period", []string{"A title without a period"}},
+		{"opening quote", `It ended. "Next," he said.`, []string{"It ended.", ` "Next," he said.`}},
+	}
+	for _, test := range tests {
+		got := segment(NewRuleSegmenter(), test.text)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Segment(%q) = %q, want %q", test.name, test.text, got, test.want)
+		}
+	}
+}
+
+func TestLanguageSegmenters(t *testing.T) {
+	tests := []struct {
+		lang string
+		text string
+		want []string
+	}{
+		{"zh", "这是第一句。这是第二句！「真的吗？」是的。", []string{"这是第一句。", "这是第二句！", "「真的吗？」", "是的。"}},
+		{"zh", "中文。English one. English two.", []string{"中文。", "English one.", " English two."}},
+		{"zh", "真的吗?是的!好。", []string{"真的吗?", "是的!", "好。"}},
+		{"zh", "版本3.5很好。见 Fig. 2 所示。", []string{"版本3.5很好。", "见 Fig. 2 所示。"}},
+		{"ja-JP", "今日は晴れです。明日は雨？", []string{"今日は晴れです。", "明日は雨？"}},
+		{"es", "Hola. ¿Cómo estás? ¡Muy bien! Ver pág. 3 del texto.", []string{"Hola.", " ¿Cómo estás?", " ¡Muy bien!", " Ver pág. 3 del texto."}},
+		{"en", "Fig. 1 shows it. Done.", []string{"Fig. 1 shows it.", " Done."}},
+		{"", "Fig. 1 shows it. Done.", []string{"Fig. 1 shows it.", " Done."}},
+	}
+	for _, test := range tests {
+		got := segment(SegmenterForLang(test.lang), test.text)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Segment(%q) = %q, want %q", test.lang, test.text, got, test.want)
+		}
+	}
+}
+
+func TestUtf16Offsets(t *testing.T) {
+	text := "α 𝛽 γ"
+	if n := Utf16Len(text); n != 6 {
+		t.Errorf("Utf16Len = %d, want 6", n)
+	}
+	if got := SentenceText(text, Sentence{Start: 2, End: 4}); got != "𝛽" {
+		t.Errorf("SentenceText = %q, want %q", got, "𝛽")
+	}
+	//an offset inside a surrogate pair moves to the end of the code point
+	if got := ByteOffset(text, 3); got != len("α 𝛽") {
+		t.Errorf("ByteOffset = %d, want %d", got, len("α 𝛽"))
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+)
+
+type SentenceLocation struct {
+	Sentence  Sentence `bson:"sentence" json:"sentence"`
+	Text      string   `bson:"text,omitempty" json:"text,omitempty"`
+	Scope     string   `bson:"scope,omitempty" json:"scope,omitempty"`         //body, ack, back, abstract[-type][-lang] or sub-<id>
+	SectionId string   `bson:"sectionId,omitempty" json:"sectionId,omitempty"` //innermost section, only in the body
+	Path      string   `bson:"path,omitempty" json:"path,omitempty"`           //child indexes from the scope down to the block
+}
+
+type sentenceScope struct {
+	name  string
+	nodes []Node
+}
+
+func (this *Article) sentenceScopes() (scopes []sentenceScope) {
+	scopes = append(scopes, sentenceScope{"body", this.Body})
+	for _, abstract := range this.Abstracts {
+		scopes = append(scopes, sentenceScope{abstractScope(abstract), abstract.Children})
+	}
+	if len(this.Abstract) > 0 {
+		//usually shares its nodes with an entry of Abstracts, but not once loaded from the db
+		scopes = append(scopes, sentenceScope{abstractScope(this.PrimaryAbstract()), this.Abstract})
+	}
+	scopes = append(scopes, sentenceScope{"ack", this.Ack})
+	scopes = append(scopes, sentenceScope{"back", this.Back})
+	for i, sub := range this.SubArticles {
+		id := sub.XmlId
+		if id == "" {
+			id = strconv.Itoa(i)
+		}
+		scopes = append(scopes, sentenceScope{"sub-" + id, sub.Body})
+	}
+	return
+}
+
+func abstractScope(abstract Abstract) string {
+	scope := "abstract"
+	if abstract.Type != "" {
+		scope += "-" + abstract.Type
+	}
+	if abstract.Lang != "" {
+		scope += "-" + abstract.Lang
+	}
+	return scope
+}
+
+func (this *Article) AssignSentenceIds() {
+	//ids hash the scope and the sentence text, so they survive re-imports that move sentences around
+	for _, scope := range this.sentenceScopes() {
+		seen := map[string]int{}
+		walkSentences(scope.nodes, "", "", func(block *Node, path string, sectionId string) {
+			text := inlineText(block.Children)
+			for i := range block.Sentences {
+				sent := &block.Sentences[i]
+				sum := sha1.Sum([]byte(scope.name + "\x00" + NormalizeSpace(SentenceText(text, *sent))))
+				id := hex.EncodeToString(sum[:6])
+				seen[id]++
+				if seen[id] > 1 {
+					id += "-" + strconv.Itoa(seen[id])
+				}
+				sent.Id = id
+			}
+		})
+	}
+}
+
+func walkSentences(nodes []Node, path string, sectionId string, fn func(block *Node, path string, sectionId string)) {
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Type != "tag" {
+			continue
+		}
+		nodePath := path + "/" + strconv.Itoa(i)
+		nodeSection := sectionId
+		if node.Tag == "sec" && node.Props["id"] != "" {
+			nodeSection = node.Props["id"]
+		}
+		if len(node.Sentences) > 0 {
+			fn(node, nodePath, nodeSection)
+		}
+		walkSentences(node.Children, nodePath, nodeSection, fn)
+	}
+}
+
+func (this *Article) SentenceById(id string) (location SentenceLocation, found bool) {
+	for _, scope := range this.sentenceScopes() {
+		walkSentences(scope.nodes, "", "", func(block *Node, path string, sectionId string) {
+			if found {
+				return
+			}
+			for _, sent := range block.Sentences {
+				if sent.Id != id {
+					continue
+				}
+				location = SentenceLocation{
+					Sentence: sent,
+					Text:     SentenceText(inlineText(block.Children), sent),
+					Scope:    scope.name,
+					Path:     path,
+				}
+				if scope.name == "body" {
+					location.SectionId = sectionId
+				}
+				found = true
+				return
+			}
+		})
+		if found {
+			return
+		}
+	}
+	return
+}
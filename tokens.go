@@ -0,0 +1,161 @@
+package models
+
+import (
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	TokenWord   = "word"
+	TokenNumber = "number"
+)
+
+// words of at least this many letters count as long for Lix
+const LongWordLength = 7
+
+type Token struct {
+	Text  string `bson:"text" json:"text"`
+	Kind  string `bson:"kind" json:"kind"`
+	Start int    `bson:"start" json:"start"` //UTF-16 code units, like Sentence offsets
+	End   int    `bson:"end" json:"end"`
+}
+
+type TextStats struct {
+	Sentences int `bson:"sentences" json:"sentences"`
+	Words     int `bson:"words" json:"words"`
+	Letters   int `bson:"letters" json:"letters"`
+	LongWords int `bson:"longWords" json:"longWords"` //at least LongWordLength letters
+}
+
+func Tokenize(text string) (tokens []Token) {
+	//words are runs of letters and digits, joined across "-", "'" and "." when more follow, e.g. "COVID-19" or "3.5";
+	//han and kana are written without spaces, so each character is a word of its own
+	units := 0
+	var current *Token
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		width := utf16.RuneLen(r)
+		if width < 1 {
+			width = 1
+		}
+
+		if isIdeograph(r) {
+			current = nil
+			tokens = append(tokens, Token{Text: text[i : i+size], Kind: TokenWord, Start: units, End: units + width})
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) || (current != nil && unicode.IsMark(r)) {
+			if current == nil {
+				tokens = append(tokens, Token{Kind: TokenNumber, Start: units})
+				current = &tokens[len(tokens)-1]
+			}
+			if unicode.IsLetter(r) {
+				current.Kind = TokenWord
+			}
+			current.End = units + width
+		} else if current != nil && isJoiner(r) && i+size < len(text) {
+			next, _ := utf8.DecodeRuneInString(text[i+size:])
+			if !unicode.IsLetter(next) && !unicode.IsDigit(next) {
+				current = nil
+			}
+		} else {
+			current = nil
+		}
+
+		units += width
+		i += size
+	}
+
+	for i := range tokens {
+		tokens[i].Text = SentenceText(text, Sentence{Start: tokens[i].Start, End: tokens[i].End})
+	}
+	return
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+func isJoiner(r rune) bool {
+	return r == '-' || r == '\'' || r == '’' || r == '.'
+}
+
+func WordCount(text string) (words int) {
+	for _, token := range Tokenize(text) {
+		if token.Kind == TokenWord {
+			words++
+		}
+	}
+	return
+}
+
+func (this *Node) Tokens() []Token {
+	//tokens of this block's text, the text its Sentences are offset into
+	return Tokenize(inlineText(this.Children))
+}
+
+func (this *Node) SentenceTokens(i int) (tokens []Token) {
+	//tokens of the i-th sentence, offsets still relative to the block
+	if i < 0 || i >= len(this.Sentences) {
+		return
+	}
+	sent := this.Sentences[i]
+	for _, token := range this.Tokens() {
+		if token.Start >= sent.Start && token.End <= sent.End {
+			tokens = append(tokens, token)
+		}
+	}
+	return
+}
+
+func NodesStats(nodes []Node) (stats TextStats) {
+	//over every block in nodes, nested ones included
+	for _, node := range nodes {
+		if node.Type != "tag" {
+			continue
+		}
+		stats.Sentences += len(node.Sentences)
+		if len(node.Sentences) > 0 {
+			for _, token := range node.Tokens() {
+				if token.Kind != TokenWord {
+					continue
+				}
+				letters := 0
+				for _, r := range token.Text {
+					if unicode.IsLetter(r) {
+						letters++
+					}
+				}
+				stats.Words++
+				stats.Letters += letters
+				if letters >= LongWordLength {
+					stats.LongWords++
+				}
+			}
+		}
+		child := NodesStats(node.Children)
+		stats.Sentences += child.Sentences
+		stats.Words += child.Words
+		stats.Letters += child.Letters
+		stats.LongWords += child.LongWords
+	}
+	return
+}
+
+func (this *Article) BodyStats() TextStats {
+	return NodesStats(this.Body)
+}
+
+func (this *TextStats) WordsPerSentence() float64 {
+	if this.Sentences == 0 {
+		return 0
+	}
+	return float64(this.Words) / float64(this.Sentences)
+}
+
+func (this *TextStats) Lix() float64 {
+	//readability index that works across languages: words per sentence plus percentage of long words
+	if this.Words == 0 {
+		return 0
+	}
+	return this.WordsPerSentence() + 100*float64(this.LongWords)/float64(this.Words)
+}